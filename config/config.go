@@ -0,0 +1,217 @@
+// Package config resolves the options of the init binary from the command
+// line flags, the env vars and a config file.
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// ScriptSettingsKey is the config file key of the per-script settings.
+const ScriptSettingsKey = "scriptSettings"
+
+// Source is where the effective value of an option comes from.
+type Source string
+
+// Sources of the option values, in increasing order of precedence.
+const (
+	SourceDefault Source = "default"
+	SourceFile    Source = "file"
+	SourceEnv     Source = "env"
+	SourceFlag    Source = "flag"
+)
+
+// ScriptSettings are the settings of a script in the config file.
+type ScriptSettings struct {
+	// Skip disables the script.
+	Skip bool `json:"skip,omitempty"`
+	// Env contains the env vars passed to the script only, overriding the
+	// common env vars.
+	Env map[string]string `json:"env,omitempty"`
+}
+
+// Config resolves the options defined as flags of a flag set. The value of
+// an option comes from, in order of precedence, the command line flag, the
+// env var of the option, the config file and the flag default.
+type Config struct {
+	fs      *flag.FlagSet
+	envVars map[string]string
+	sources map[string]Source
+
+	// Scripts contains the per-script settings of the config file by script
+	// name.
+	Scripts map[string]ScriptSettings
+}
+
+// New returns a Config for the options of a flag set.
+func New(fs *flag.FlagSet) *Config {
+	return &Config{
+		fs:      fs,
+		envVars: map[string]string{},
+		sources: map[string]Source{},
+		Scripts: map[string]ScriptSettings{},
+	}
+}
+
+// SetEnv sets the env var an option is read from.
+func (c *Config) SetEnv(option, envVar string) *Config {
+	c.envVars[option] = envVar
+	return c
+}
+
+// Load resolves the options of the parsed flag set, reading the config file
+// if the path is not empty. The config file is YAML or JSON, with a key per
+// option named after the flag, and the per-script settings. Unknown keys and
+// invalid values are rejected.
+func (c *Config) Load(path string) error {
+	fileValues := map[string]json.RawMessage{}
+	if path != "" {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if err := c.parseFile(data, fileValues); err != nil {
+			return fmt.Errorf("invalid config file %q: %v", path, err)
+		}
+	}
+
+	c.fs.Visit(func(f *flag.Flag) {
+		c.sources[f.Name] = SourceFlag
+	})
+
+	var err error
+	c.fs.VisitAll(func(f *flag.Flag) {
+		if err != nil || c.sources[f.Name] == SourceFlag {
+			return
+		}
+
+		if envVar, ok := c.envVars[f.Name]; ok {
+			if value := os.Getenv(envVar); value != "" {
+				if setErr := f.Value.Set(value); setErr != nil {
+					err = fmt.Errorf("invalid value %q of env var %s: %v", value, envVar, setErr)
+					return
+				}
+				c.sources[f.Name] = SourceEnv
+				return
+			}
+		}
+
+		if raw, ok := fileValues[f.Name]; ok {
+			if setErr := setFileValue(f.Value, raw); setErr != nil {
+				err = fmt.Errorf("invalid value of %s in config file %q: %v", f.Name, path, setErr)
+				return
+			}
+			c.sources[f.Name] = SourceFile
+			return
+		}
+
+		c.sources[f.Name] = SourceDefault
+	})
+
+	return err
+}
+
+// parseFile parses the content of a config file into the raw values of the
+// options and the per-script settings.
+func (c *Config) parseFile(data []byte, values map[string]json.RawMessage) error {
+	jsonData, err := yaml.YAMLToJSON(data)
+	if err != nil {
+		return err
+	}
+
+	raw := map[string]json.RawMessage{}
+	if err := json.Unmarshal(jsonData, &raw); err != nil {
+		return err
+	}
+
+	for key, value := range raw {
+		if key == ScriptSettingsKey {
+			dec := json.NewDecoder(bytes.NewReader(value))
+			dec.DisallowUnknownFields()
+			if err := dec.Decode(&c.Scripts); err != nil {
+				return fmt.Errorf("%s: %v", key, err)
+			}
+			continue
+		}
+		// A config file can't load another config file.
+		if key == "config" || c.fs.Lookup(key) == nil {
+			return fmt.Errorf("unknown option %q", key)
+		}
+		values[key] = value
+	}
+
+	return nil
+}
+
+// setFileValue sets a flag value from a JSON value. Each item of a list is set
+// in order, for the flags that can be repeated.
+func setFileValue(value flag.Value, raw json.RawMessage) error {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return err
+	}
+
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case []interface{}:
+		for _, item := range v {
+			if err := value.Set(fmt.Sprint(item)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case map[string]interface{}:
+		return fmt.Errorf("unexpected object")
+	default:
+		return value.Set(fmt.Sprint(v))
+	}
+}
+
+// Source returns where the effective value of an option comes from.
+func (c *Config) Source(option string) Source {
+	return c.sources[option]
+}
+
+// Dump writes the effective value of each option, sorted by name, and where
+// it comes from, followed by the per-script settings.
+func (c *Config) Dump(w io.Writer) error {
+	var lines []string
+	c.fs.VisitAll(func(f *flag.Flag) {
+		lines = append(lines, fmt.Sprintf("%s=%q (%s)", f.Name, f.Value.String(), c.Source(f.Name)))
+	})
+
+	names := make([]string, 0, len(c.Scripts))
+	for name := range c.Scripts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		s := c.Scripts[name]
+		lines = append(lines, fmt.Sprintf("%s.%s.skip=%t (%s)", ScriptSettingsKey, name, s.Skip, SourceFile))
+
+		envNames := make([]string, 0, len(s.Env))
+		for k := range s.Env {
+			envNames = append(envNames, k)
+		}
+		sort.Strings(envNames)
+		for _, k := range envNames {
+			lines = append(lines, fmt.Sprintf("%s.%s.env.%s=%q (%s)", ScriptSettingsKey, name, k, s.Env[k], SourceFile))
+		}
+	}
+
+	_, err := io.WriteString(w, strings.Join(lines, "\n")+"\n")
+	return err
+}
@@ -0,0 +1,214 @@
+package config
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// stringList is a flag.Value for a repeated string flag.
+type stringList []string
+
+func (l *stringList) String() string     { return strings.Join(*l, ":") }
+func (l *stringList) Set(v string) error { *l = append(*l, v); return nil }
+
+// testOptions are the options of a test flag set.
+type testOptions struct {
+	scripts   stringList
+	dsName    *string
+	nodeName  *string
+	stripANSI *bool
+	headKB    *int
+}
+
+func newTestFlagSet() (*flag.FlagSet, *testOptions) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	o := &testOptions{}
+	fs.Var(&o.scripts, "scripts", "")
+	o.dsName = fs.String("dsName", "", "")
+	o.nodeName = fs.String("nodeName", "", "")
+	o.stripANSI = fs.Bool("stripANSI", false, "")
+	o.headKB = fs.Int("captureHeadKB", 32, "")
+	fs.String("config", "", "")
+	return fs, o
+}
+
+func TestLoad(t *testing.T) {
+	testcases := []struct {
+		name         string
+		file         string
+		args         []string
+		envvars      map[string]string
+		wantErr      bool
+		wantScripts  []string
+		wantDSName   string
+		wantNodeName string
+		wantANSI     bool
+		wantHeadKB   int
+		wantSources  map[string]Source
+		wantSettings map[string]ScriptSettings
+	}{
+		{
+			name:        "defaults",
+			wantHeadKB:  32,
+			wantSources: map[string]Source{"dsName": SourceDefault, "captureHeadKB": SourceDefault},
+		},
+		{
+			name: "yaml file",
+			file: `
+scripts: [/scripts, /site]
+dsName: ds1
+stripANSI: true
+captureHeadKB: 8
+scriptSettings:
+  02-limits:
+    skip: true
+  01-lio:
+    env:
+      FOO: bar
+`,
+			wantScripts: []string{"/scripts", "/site"},
+			wantDSName:  "ds1",
+			wantANSI:    true,
+			wantHeadKB:  8,
+			wantSources: map[string]Source{"dsName": SourceFile, "captureHeadKB": SourceFile, "nodeName": SourceDefault},
+			wantSettings: map[string]ScriptSettings{
+				"02-limits": {Skip: true},
+				"01-lio":    {Env: map[string]string{"FOO": "bar"}},
+			},
+		},
+		{
+			name:        "json file",
+			file:        `{"dsName": "ds1", "captureHeadKB": 4}`,
+			wantDSName:  "ds1",
+			wantHeadKB:  4,
+			wantSources: map[string]Source{"dsName": SourceFile},
+		},
+		{
+			name:         "flag over env over file",
+			file:         "dsName: ds1\nnodeName: node1\ncaptureHeadKB: 8\n",
+			args:         []string{"-captureHeadKB=16"},
+			envvars:      map[string]string{"DAEMONSET_NAME": "ds2", "NODE_NAME": ""},
+			wantDSName:   "ds2",
+			wantNodeName: "node1",
+			wantHeadKB:   16,
+			wantSources:  map[string]Source{"dsName": SourceEnv, "nodeName": SourceFile, "captureHeadKB": SourceFlag},
+		},
+		{
+			name:    "unknown option",
+			file:    "dsname: ds1\n",
+			wantErr: true,
+		},
+		{
+			name:    "unknown script setting",
+			file:    "scriptSettings:\n  01-lio:\n    skipped: true\n",
+			wantErr: true,
+		},
+		{
+			name:    "invalid value",
+			file:    "captureHeadKB: lots\n",
+			wantErr: true,
+		},
+		{
+			name:    "nested config",
+			file:    "config: /other.yaml\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			for k, v := range tc.envvars {
+				os.Setenv(k, v)
+				defer os.Unsetenv(k)
+			}
+
+			var path string
+			if tc.file != "" {
+				dir, err := ioutil.TempDir("", "init-config-test")
+				if err != nil {
+					t.Fatalf("failed to create directory: %v", err)
+				}
+				defer os.RemoveAll(dir)
+				path = filepath.Join(dir, "config.yaml")
+				if err := ioutil.WriteFile(path, []byte(tc.file), 0644); err != nil {
+					t.Fatalf("failed to write config file: %v", err)
+				}
+			}
+
+			fs, o := newTestFlagSet()
+			if err := fs.Parse(tc.args); err != nil {
+				t.Fatalf("failed to parse flags: %v", err)
+			}
+
+			c := New(fs).SetEnv("dsName", "DAEMONSET_NAME").SetEnv("nodeName", "NODE_NAME")
+			err := c.Load(path)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tc.wantErr {
+				return
+			}
+
+			if !reflect.DeepEqual([]string(o.scripts), tc.wantScripts) {
+				t.Errorf("unexpected scripts:\n\t(WNT) %v\n\t(GOT) %v", tc.wantScripts, o.scripts)
+			}
+			if *o.dsName != tc.wantDSName {
+				t.Errorf("unexpected dsName:\n\t(WNT) %s\n\t(GOT) %s", tc.wantDSName, *o.dsName)
+			}
+			if *o.nodeName != tc.wantNodeName {
+				t.Errorf("unexpected nodeName:\n\t(WNT) %s\n\t(GOT) %s", tc.wantNodeName, *o.nodeName)
+			}
+			if *o.stripANSI != tc.wantANSI {
+				t.Errorf("unexpected stripANSI:\n\t(WNT) %t\n\t(GOT) %t", tc.wantANSI, *o.stripANSI)
+			}
+			if *o.headKB != tc.wantHeadKB {
+				t.Errorf("unexpected captureHeadKB:\n\t(WNT) %d\n\t(GOT) %d", tc.wantHeadKB, *o.headKB)
+			}
+			for option, want := range tc.wantSources {
+				if got := c.Source(option); got != want {
+					t.Errorf("unexpected source of %s:\n\t(WNT) %s\n\t(GOT) %s", option, want, got)
+				}
+			}
+			if tc.wantSettings != nil && !reflect.DeepEqual(c.Scripts, tc.wantSettings) {
+				t.Errorf("unexpected script settings:\n\t(WNT) %v\n\t(GOT) %v", tc.wantSettings, c.Scripts)
+			}
+		})
+	}
+}
+
+func TestDump(t *testing.T) {
+	fs, _ := newTestFlagSet()
+	if err := fs.Parse([]string{"-dsName=ds1"}); err != nil {
+		t.Fatalf("failed to parse flags: %v", err)
+	}
+
+	c := New(fs)
+	if err := c.Load(""); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	c.Scripts["01-lio"] = ScriptSettings{Skip: true, Env: map[string]string{"FOO": "bar"}}
+
+	var out bytes.Buffer
+	if err := c.Dump(&out); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := `captureHeadKB="32" (default)
+config="" (default)
+dsName="ds1" (flag)
+nodeName="" (default)
+scripts="" (default)
+stripANSI="false" (default)
+scriptSettings.01-lio.skip=true (file)
+scriptSettings.01-lio.env.FOO="bar" (file)
+`
+	if out.String() != want {
+		t.Errorf("unexpected dump:\n\t(WNT) %s\n\t(GOT) %s", want, out.String())
+	}
+}
@@ -0,0 +1,50 @@
+package fileutil
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "report.json")
+
+	// The second write replaces the file.
+	for _, content := range []string{"first\n", "second\n"} {
+		if err := WriteFileAtomic(path, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read file: %v", err)
+	}
+	if string(data) != "second\n" {
+		t.Errorf("unexpected content:\n\t(WNT) %q\n\t(GOT) %q", "second\n", data)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("failed to stat file: %v", err)
+	}
+	if info.Mode().Perm() != 0644 {
+		t.Errorf("unexpected mode:\n\t(WNT) %s\n\t(GOT) %s", os.FileMode(0644), info.Mode().Perm())
+	}
+
+	// No temporary files must be left behind.
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("failed to read directory: %v", err)
+	}
+	if len(files) != 1 {
+		t.Errorf("unexpected number of files:\n\t(WNT) %d\n\t(GOT) %d", 1, len(files))
+	}
+
+	// A missing directory fails the write.
+	if err := WriteFileAtomic(filepath.Join(dir, "missing", "report.json"), nil, 0644); err == nil {
+		t.Error("expected an error")
+	}
+}
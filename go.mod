@@ -1,6 +1,6 @@
 module github.com/storageos/init
 
-go 1.15
+go 1.16
 
 require (
 	github.com/evanphx/json-patch v4.5.0+incompatible // indirect
@@ -8,8 +8,8 @@ require (
 	github.com/golang/mock v1.4.4
 	github.com/json-iterator/go v1.1.7 // indirect
 	github.com/pkg/errors v0.8.1 // indirect
+	go.starlark.net v0.0.0-20201118183435-e55f603d8c79
 	golang.org/x/net v0.0.0-20190827160401-ba9fcec4b297 // indirect
-	golang.org/x/sys v0.0.0-20190826190057-c7b8b68b1456 // indirect
 	google.golang.org/appengine v1.6.1 // indirect
 	gopkg.in/yaml.v2 v2.2.2 // indirect
 	k8s.io/api v0.0.0-20190819141258-3544db3b9e44
@@ -17,4 +17,5 @@ require (
 	k8s.io/client-go v0.0.0-20190620085101-78d2af792bab
 	k8s.io/klog v0.4.0 // indirect
 	k8s.io/kube-openapi v0.0.0-20190816220812-743ec37842bf // indirect
+	sigs.k8s.io/yaml v1.1.0
 )
@@ -0,0 +1,50 @@
+package host
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// KernelRelease returns the release of the host kernel, e.g. 5.4.0-42-generic.
+func (r Root) KernelRelease() (string, error) {
+	return r.readString("/proc/sys/kernel/osrelease")
+}
+
+// ModuleLoaded returns true if a kernel module is loaded and live in the host
+// kernel.
+func (r Root) ModuleLoaded(name string) (bool, error) {
+	state, err := r.readString("/sys/module", name, "initstate")
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return state == "live", nil
+}
+
+// Module states returned by ModuleState, besides the initstate of a loadable
+// module, e.g. live, coming or going.
+const (
+	// ModuleBuiltin is the state of a module built in the kernel.
+	ModuleBuiltin = "builtin"
+	// ModuleNotLoaded is the state of a module that is not loaded.
+	ModuleNotLoaded = ""
+)
+
+// ModuleState returns the state of a kernel module in the host kernel: the
+// initstate of a loadable module, e.g. live, ModuleBuiltin for a module built
+// in the kernel, with parameters or a version, or ModuleNotLoaded.
+func (r Root) ModuleState(name string) (string, error) {
+	state, err := r.readString("/sys/module", name, "initstate")
+	if err == nil {
+		return state, nil
+	}
+	if !os.IsNotExist(err) {
+		return "", err
+	}
+	if r.exists(filepath.Join("/sys/module", name)) {
+		return ModuleBuiltin, nil
+	}
+	return ModuleNotLoaded, nil
+}
@@ -0,0 +1,4 @@
+// Package host provides access to the host filesystem and implements the
+// built-in host checks. The host filesystem may be mounted under a root
+// prefix, e.g. /host, and all the paths are resolved relative to it.
+package host
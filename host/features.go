@@ -0,0 +1,149 @@
+package host
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Features are the capabilities of the host relevant to StorageOS.
+type Features struct {
+	// TargetCoreUser is set when the target_core_user kernel module is loaded,
+	// built in or installed.
+	TargetCoreUser bool
+	// CgroupV2 is set when the unified cgroup v2 hierarchy is mounted.
+	CgroupV2 bool
+	// KernelMajor and KernelMinor are the host kernel version numbers.
+	KernelMajor int
+	KernelMinor int
+	// HugePages is set when huge pages are reserved.
+	HugePages bool
+}
+
+// Features discovers the host features.
+func (r Root) Features() (Features, error) {
+	var f Features
+
+	release, err := r.KernelRelease()
+	if err != nil {
+		return f, err
+	}
+	if f.KernelMajor, f.KernelMinor, err = parseKernelVersion(release); err != nil {
+		return f, err
+	}
+
+	if f.TargetCoreUser, err = r.ModuleAvailable(release, "target_core_user"); err != nil {
+		return f, err
+	}
+
+	f.CgroupV2 = r.exists("/sys/fs/cgroup/cgroup.controllers")
+
+	if f.HugePages, err = r.hugePagesReserved(); err != nil {
+		return f, err
+	}
+
+	return f, nil
+}
+
+// ModuleAvailable returns true if a kernel module is loaded, built in the
+// kernel or installed in the modules directory of a kernel release.
+func (r Root) ModuleAvailable(release, name string) (bool, error) {
+	loaded, err := r.ModuleLoaded(name)
+	if err != nil || loaded {
+		return loaded, err
+	}
+
+	// Look up the module in the list of built in and installed modules.
+	for _, list := range []string{"modules.builtin", "modules.dep"} {
+		found, err := r.moduleListed(filepath.Join("/lib/modules", release, list), name)
+		if err != nil || found {
+			return found, err
+		}
+	}
+
+	return false, nil
+}
+
+// moduleListed returns true if a kernel module is listed in a modules.dep or
+// modules.builtin file, e.g. kernel/drivers/target/target_core_user.ko.xz.
+func (r Root) moduleListed(path, name string) (bool, error) {
+	file, err := os.Open(r.Path(path))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// modules.dep lines are "<module path>: <dependencies>".
+		modPath := strings.SplitN(scanner.Text(), ":", 2)[0]
+		base := filepath.Base(modPath)
+		if i := strings.Index(base, ".ko"); i >= 0 {
+			base = base[:i]
+		}
+		if strings.Replace(base, "-", "_", -1) == name {
+			return true, nil
+		}
+	}
+
+	return false, scanner.Err()
+}
+
+// hugePagesReserved returns true if the host has reserved huge pages.
+func (r Root) hugePagesReserved() (bool, error) {
+	file, err := os.Open(r.Path("/proc/meminfo"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[0] == "HugePages_Total:" {
+			total, err := strconv.Atoi(fields[1])
+			if err != nil {
+				return false, fmt.Errorf("invalid HugePages_Total %q: %v", fields[1], err)
+			}
+			return total > 0, nil
+		}
+	}
+
+	return false, scanner.Err()
+}
+
+// exists returns true if a host path exists.
+func (r Root) exists(path string) bool {
+	_, err := os.Stat(r.Path(path))
+	return err == nil
+}
+
+// parseKernelVersion returns the major and minor version numbers of a kernel
+// release, e.g. 5 and 4 for 5.4.0-42-generic.
+func parseKernelVersion(release string) (major, minor int, err error) {
+	parts := strings.SplitN(release, ".", 3)
+	if len(parts) < 2 {
+		return 0, 0, fmt.Errorf("invalid kernel release %q", release)
+	}
+	if major, err = strconv.Atoi(parts[0]); err != nil {
+		return 0, 0, fmt.Errorf("invalid kernel release %q: %v", release, err)
+	}
+	// The minor version may be followed by a suffix, e.g. 3.10-foo.
+	digits := strings.IndexFunc(parts[1], func(c rune) bool { return c < '0' || c > '9' })
+	if digits < 0 {
+		digits = len(parts[1])
+	}
+	if minor, err = strconv.Atoi(parts[1][:digits]); err != nil {
+		return 0, 0, fmt.Errorf("invalid kernel release %q: %v", release, err)
+	}
+	return major, minor, nil
+}
@@ -0,0 +1,114 @@
+package host
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Mount is a mount point of a mountinfo file, see proc(5).
+type Mount struct {
+	ID       int
+	ParentID int
+	// MajorMinor is the device number, e.g. 8:1.
+	MajorMinor string
+	// Root is the root of the mount in its filesystem.
+	Root string
+	// MountPoint is the mount point relative to the process root.
+	MountPoint string
+	// Options are the per mount options.
+	Options string
+	// Optional are the optional fields, e.g. shared:1.
+	Optional []string
+	// FSType is the filesystem type, e.g. ext4.
+	FSType string
+	// Source is the mount source, e.g. /dev/sda1.
+	Source string
+	// SuperOptions are the per superblock options.
+	SuperOptions string
+}
+
+// MountInfo returns the mount points of a process, read from its mountinfo
+// file. With the host PID namespace, PID 1 is the host init process.
+func (r Root) MountInfo(pid int) ([]Mount, error) {
+	f, err := os.Open(r.Path("/proc", strconv.Itoa(pid), "mountinfo"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseMountInfo(f)
+}
+
+// ParseMountInfo parses the content of a mountinfo file.
+func ParseMountInfo(rd io.Reader) ([]Mount, error) {
+	mounts := []Mount{}
+
+	scanner := bufio.NewScanner(rd)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		// The optional fields are terminated by a single hyphen.
+		sep := -1
+		for i := 6; i < len(fields); i++ {
+			if fields[i] == "-" {
+				sep = i
+				break
+			}
+		}
+		if sep < 0 || len(fields) < sep+4 {
+			return nil, fmt.Errorf("line %d: invalid mountinfo line", line)
+		}
+
+		id, err := strconv.Atoi(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid mount ID %q", line, fields[0])
+		}
+		parentID, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid parent ID %q", line, fields[1])
+		}
+
+		mounts = append(mounts, Mount{
+			ID:           id,
+			ParentID:     parentID,
+			MajorMinor:   fields[2],
+			Root:         unescapeMountPath(fields[3]),
+			MountPoint:   unescapeMountPath(fields[4]),
+			Options:      fields[5],
+			Optional:     fields[6:sep],
+			FSType:       fields[sep+1],
+			Source:       unescapeMountPath(fields[sep+2]),
+			SuperOptions: fields[sep+3],
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return mounts, nil
+}
+
+// unescapeMountPath replaces the octal escapes of the space, tab, newline and
+// backslash characters of a mountinfo path, e.g. \040.
+func unescapeMountPath(path string) string {
+	if !strings.Contains(path, `\`) {
+		return path
+	}
+	var b strings.Builder
+	for i := 0; i < len(path); i++ {
+		if path[i] == '\\' && i+4 <= len(path) {
+			if c, err := strconv.ParseUint(path[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(c))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(path[i])
+	}
+	return b.String()
+}
@@ -0,0 +1,113 @@
+package host
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// EnvVar is the env var that contains the host root prefix passed to the
+// scripts. It's empty when the host root is "/", so that the scripts can
+// prefix absolute paths with it, e.g. "${HOST_ROOT}/sys".
+const EnvVar = "HOST_ROOT"
+
+// DefaultRoot is the default host root.
+const DefaultRoot = "/"
+
+// maxSymlinks is the maximum number of symlinks followed to resolve a host
+// path, as in the Linux kernel.
+const maxSymlinks = 40
+
+// Root is the path where the host root filesystem is mounted.
+type Root string
+
+// NewRoot returns a Root for a given path. An empty path is the default root.
+func NewRoot(path string) Root {
+	if path == "" {
+		return DefaultRoot
+	}
+	return Root(filepath.Clean(path))
+}
+
+// Path returns the path of an absolute host path under the root.
+func (r Root) Path(elem ...string) string {
+	return filepath.Join(append([]string{string(r)}, elem...)...)
+}
+
+// ResolvePath returns the path under the root of an absolute host path, with
+// the symlinks resolved as on the host: an absolute symlink target is
+// relative to the root, and a ".." at the root stays at the root, so that the
+// path never leaves the root. The part of the path that doesn't exist is
+// joined as is.
+func (r Root) ResolvePath(p string) (string, error) {
+	if !path.IsAbs(p) {
+		return "", fmt.Errorf("path %q is not absolute", p)
+	}
+
+	resolved := "/"
+	remaining := p
+	links := 0
+	for remaining != "" {
+		part := remaining
+		remaining = ""
+		if i := strings.IndexByte(part, '/'); i >= 0 {
+			part, remaining = part[:i], part[i+1:]
+		}
+
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			resolved = path.Dir(resolved)
+			continue
+		}
+
+		next := path.Join(resolved, part)
+		info, err := os.Lstat(r.Path(next))
+		if os.IsNotExist(err) {
+			return r.Path(path.Join(next, remaining)), nil
+		}
+		if err != nil {
+			return "", err
+		}
+		if info.Mode()&os.ModeSymlink == 0 {
+			resolved = next
+			continue
+		}
+
+		links++
+		if links > maxSymlinks {
+			return "", fmt.Errorf("too many symlinks resolving %q", p)
+		}
+		target, err := os.Readlink(r.Path(next))
+		if err != nil {
+			return "", err
+		}
+		if path.IsAbs(target) {
+			resolved = "/"
+		}
+		remaining = target + "/" + remaining
+	}
+	return r.Path(resolved), nil
+}
+
+// Env returns the value of the HOST_ROOT env var for the root.
+func (r Root) Env() string {
+	if r == DefaultRoot || r == "" {
+		return ""
+	}
+	return string(r)
+}
+
+// readString reads a host file and returns its content without the
+// surrounding whitespace.
+func (r Root) readString(elem ...string) (string, error) {
+	data, err := ioutil.ReadFile(r.Path(elem...))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
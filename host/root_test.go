@@ -0,0 +1,281 @@
+package host
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testRoot is a fixture tree of the host root filesystem.
+const testRoot = "testdata/root"
+
+func TestRootPath(t *testing.T) {
+	testcases := []struct {
+		name     string
+		root     string
+		elem     []string
+		wantPath string
+		wantEnv  string
+	}{
+		{
+			name:     "default root",
+			root:     "",
+			elem:     []string{"/sys/module"},
+			wantPath: "/sys/module",
+			wantEnv:  "",
+		},
+		{
+			name:     "slash root",
+			root:     "/",
+			elem:     []string{"/sys", "module"},
+			wantPath: "/sys/module",
+			wantEnv:  "",
+		},
+		{
+			name:     "prefixed root",
+			root:     "/host/",
+			elem:     []string{"/sys/module"},
+			wantPath: "/host/sys/module",
+			wantEnv:  "/host",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := NewRoot(tc.root)
+			if path := r.Path(tc.elem...); path != tc.wantPath {
+				t.Errorf("unexpected path:\n\t(WNT) %s\n\t(GOT) %s", tc.wantPath, path)
+			}
+			if env := r.Env(); env != tc.wantEnv {
+				t.Errorf("unexpected env:\n\t(WNT) %s\n\t(GOT) %s", tc.wantEnv, env)
+			}
+		})
+	}
+}
+
+func TestKernelRelease(t *testing.T) {
+	release, err := NewRoot(testRoot).KernelRelease()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if release != "5.4.0-42-generic" {
+		t.Errorf("unexpected kernel release:\n\t(WNT) %s\n\t(GOT) %s", "5.4.0-42-generic", release)
+	}
+}
+
+func TestModuleLoaded(t *testing.T) {
+	testcases := []struct {
+		module     string
+		wantLoaded bool
+	}{
+		{module: "configfs", wantLoaded: true},
+		{module: "target_core_mod", wantLoaded: false},
+		{module: "tcm_loop", wantLoaded: false},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.module, func(t *testing.T) {
+			loaded, err := NewRoot(testRoot).ModuleLoaded(tc.module)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if loaded != tc.wantLoaded {
+				t.Errorf("unexpected module state:\n\t(WNT) %t\n\t(GOT) %t", tc.wantLoaded, loaded)
+			}
+		})
+	}
+}
+
+func TestModuleAvailable(t *testing.T) {
+	testcases := []struct {
+		module        string
+		wantAvailable bool
+	}{
+		{module: "configfs", wantAvailable: true},
+		{module: "target_core_user", wantAvailable: true},
+		{module: "target_core_mod", wantAvailable: true},
+		{module: "tcm_loop", wantAvailable: false},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.module, func(t *testing.T) {
+			available, err := NewRoot(testRoot).ModuleAvailable("5.4.0-42-generic", tc.module)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if available != tc.wantAvailable {
+				t.Errorf("unexpected module availability:\n\t(WNT) %t\n\t(GOT) %t", tc.wantAvailable, available)
+			}
+		})
+	}
+}
+
+func TestFeatures(t *testing.T) {
+	want := Features{
+		TargetCoreUser: true,
+		CgroupV2:       true,
+		KernelMajor:    5,
+		KernelMinor:    4,
+		HugePages:      false,
+	}
+
+	f, err := NewRoot(testRoot).Features()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if f != want {
+		t.Errorf("unexpected features:\n\t(WNT) %+v\n\t(GOT) %+v", want, f)
+	}
+}
+
+func TestParseKernelVersion(t *testing.T) {
+	testcases := []struct {
+		release   string
+		wantMajor int
+		wantMinor int
+		wantErr   bool
+	}{
+		{release: "5.4.0-42-generic", wantMajor: 5, wantMinor: 4},
+		{release: "3.10-foo", wantMajor: 3, wantMinor: 10},
+		{release: "4.18.0-193.el8.x86_64", wantMajor: 4, wantMinor: 18},
+		{release: "5", wantErr: true},
+		{release: "a.b", wantErr: true},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.release, func(t *testing.T) {
+			major, minor, err := parseKernelVersion(tc.release)
+			if err != nil {
+				if !tc.wantErr {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if tc.wantErr {
+				t.Fatal("expected error, got none")
+			}
+			if major != tc.wantMajor || minor != tc.wantMinor {
+				t.Errorf("unexpected version:\n\t(WNT) %d.%d\n\t(GOT) %d.%d", tc.wantMajor, tc.wantMinor, major, minor)
+			}
+		})
+	}
+}
+
+func TestModuleState(t *testing.T) {
+	testcases := map[string]string{
+		"configfs":        "live",
+		"target_core_mod": "coming",
+		"loop":            ModuleBuiltin,
+		"tcm_loop":        ModuleNotLoaded,
+	}
+
+	for module, want := range testcases {
+		t.Run(module, func(t *testing.T) {
+			state, err := NewRoot(testRoot).ModuleState(module)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if state != want {
+				t.Errorf("unexpected module state:\n\t(WNT) %q\n\t(GOT) %q", want, state)
+			}
+		})
+	}
+}
+
+func TestMountInfo(t *testing.T) {
+	mounts, err := NewRoot(testRoot).MountInfo(1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(mounts) != 5 {
+		t.Fatalf("unexpected number of mounts:\n\t(WNT) %d\n\t(GOT) %d", 5, len(mounts))
+	}
+
+	want := Mount{
+		ID:           25,
+		ParentID:     22,
+		MajorMinor:   "0:23",
+		Root:         "/",
+		MountPoint:   "/var/lib/storageos",
+		Options:      "rw,relatime",
+		Optional:     []string{"shared:20", "master:1"},
+		FSType:       "ext4",
+		Source:       "/dev/sdb1",
+		SuperOptions: "rw",
+	}
+	if !reflect.DeepEqual(mounts[3], want) {
+		t.Errorf("unexpected mount:\n\t(WNT) %+v\n\t(GOT) %+v", want, mounts[3])
+	}
+	if mounts[4].MountPoint != "/mnt/with space" {
+		t.Errorf("unexpected unescaped mount point:\n\t(WNT) %q\n\t(GOT) %q", "/mnt/with space", mounts[4].MountPoint)
+	}
+	if len(mounts[4].Optional) != 0 {
+		t.Errorf("unexpected optional fields: %v", mounts[4].Optional)
+	}
+}
+
+func TestParseMountInfoInvalid(t *testing.T) {
+	for _, data := range []string{
+		"22 1 8:1 / / rw,relatime shared:1 ext4 /dev/sda1 rw\n",
+		"x 1 8:1 / / rw - ext4 /dev/sda1 rw\n",
+	} {
+		if _, err := ParseMountInfo(strings.NewReader(data)); err == nil {
+			t.Errorf("expected error for %q", data)
+		}
+	}
+}
+
+func TestResolvePath(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{"etc/real", "var"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatalf("failed to create directory: %v", err)
+		}
+	}
+	links := map[string]string{
+		"etc/absolute": "/etc/real",
+		"etc/relative": "real",
+		"etc/escape":   "../../../../etc/real",
+		"var/chained":  "/etc/absolute",
+		"etc/loop":     "/etc/loop",
+	}
+	for link, target := range links {
+		if err := os.Symlink(target, filepath.Join(root, link)); err != nil {
+			t.Fatalf("failed to create symlink: %v", err)
+		}
+	}
+
+	testcases := []struct {
+		name     string
+		path     string
+		wantPath string
+		wantErr  bool
+	}{
+		{name: "no symlink", path: "/etc/real", wantPath: "/etc/real"},
+		{name: "absolute symlink", path: "/etc/absolute", wantPath: "/etc/real"},
+		{name: "relative symlink", path: "/etc/relative/file", wantPath: "/etc/real/file"},
+		{name: "escaping symlink", path: "/etc/escape", wantPath: "/etc/real"},
+		{name: "chained symlinks", path: "/var/chained", wantPath: "/etc/real"},
+		{name: "escaping path", path: "/../../etc/./real/..", wantPath: "/etc"},
+		{name: "missing path", path: "/etc/absolute/missing/file", wantPath: "/etc/real/missing/file"},
+		{name: "symlink loop", path: "/etc/loop", wantErr: true},
+		{name: "relative path", path: "etc/real", wantErr: true},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := NewRoot(root).ResolvePath(tc.path)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tc.wantErr {
+				return
+			}
+			if want := filepath.Join(root, tc.wantPath); got != want {
+				t.Errorf("unexpected path:\n\t(WNT) %s\n\t(GOT) %s", want, got)
+			}
+		})
+	}
+}
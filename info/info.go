@@ -7,3 +7,40 @@ package info
 type ImageInfoer interface {
 	GetContainerImage(containerName string) (string, error)
 }
+
+// Overrides are the per-node overrides of the script execution.
+type Overrides struct {
+	// Skip contains the names of the scripts to skip, mapped to the source of
+	// the override.
+	Skip map[string]string
+	// Env contains the env vars to pass to the scripts, overriding the
+	// defaults.
+	Env map[string]string
+	// EnvSource contains the source of each env var override.
+	EnvSource map[string]string
+	// Rejected contains the sources of the rejected overrides, mapped to the
+	// reason of the rejection.
+	Rejected map[string]string
+}
+
+// OverridesInfoer is an interface that can be implemented by an information
+// source to return the per-node overrides of the script execution.
+type OverridesInfoer interface {
+	GetOverrides() (*Overrides, error)
+}
+
+// ScriptSet is a set of script files from an information source.
+type ScriptSet struct {
+	// Name is the name of the set, used as the script directory name.
+	Name string
+	// Source describes where the set comes from.
+	Source string
+	// Files maps the file names to their content.
+	Files map[string][]byte
+}
+
+// ScriptsInfoer is an interface that can be implemented by an information
+// source to return script sets.
+type ScriptsInfoer interface {
+	GetScripts() ([]ScriptSet, error)
+}
@@ -0,0 +1,115 @@
+package k8s
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/storageos/init/info"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// AnnotationPrefix is the prefix of the Node annotations that override
+	// the script execution on the Node.
+	AnnotationPrefix = "init.storageos.com/"
+	// SkipAnnotation contains a comma separated list of the names of the
+	// scripts to skip on the Node, e.g. "02-limits".
+	SkipAnnotation = AnnotationPrefix + "skip"
+	// EnvAnnotationPrefix is the prefix of the Node annotations that override
+	// the script env vars, e.g. init.storageos.com/env.MINIMUM_MAX_PIDS_LIMIT.
+	EnvAnnotationPrefix = AnnotationPrefix + "env."
+)
+
+// envVarName matches the valid env var names.
+var envVarName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// reservedEnvVars are the env vars that can't be overridden with an
+// annotation: they change how the shell, the dynamic loader or the scripts
+// framework behave, and the scripts run as root in the host namespaces.
+var reservedEnvVars = []string{"PATH", "ENV", "BASH_ENV", "SHELLOPTS", "BASHOPTS", "IFS", "PS4", "HOST_ROOT"}
+
+// reservedEnvVarPrefixes are the prefixes of the env vars that can't be
+// overridden with an annotation, the dynamic loader and the init framework
+// env vars.
+var reservedEnvVarPrefixes = []string{"LD_", "INIT_"}
+
+// checkEnvVar returns an error if an env var can't be overridden with an
+// annotation.
+func checkEnvVar(name string) error {
+	if !envVarName.MatchString(name) {
+		return fmt.Errorf("invalid env var name %q", name)
+	}
+	for _, reserved := range reservedEnvVars {
+		if name == reserved {
+			return fmt.Errorf("env var %s is reserved", name)
+		}
+	}
+	for _, prefix := range reservedEnvVarPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return fmt.Errorf("env var %s is reserved, %s* env vars can't be overridden", name, prefix)
+		}
+	}
+	return nil
+}
+
+// NodeInfo implements OverridesInfoer interface for the k8s Node that init
+// runs on, reading the overrides from the Node annotations.
+type NodeInfo struct {
+	client   kubernetes.Interface
+	nodeName string
+}
+
+// NewNodeInfo returns an initialized NodeInfo for a given Node.
+func NewNodeInfo(client kubernetes.Interface, nodeName string) *NodeInfo {
+	return &NodeInfo{
+		client:   client,
+		nodeName: nodeName,
+	}
+}
+
+// GetOverrides returns the script overrides set in the Node annotations.
+func (i *NodeInfo) GetOverrides() (*info.Overrides, error) {
+	node, err := i.client.CoreV1().Nodes().Get(i.nodeName, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return parseOverrides(node.Annotations), nil
+}
+
+// parseOverrides returns the script overrides in a set of annotations. The
+// env var overrides of reserved or invalid env vars are rejected.
+func parseOverrides(annotations map[string]string) *info.Overrides {
+	o := &info.Overrides{
+		Skip:      map[string]string{},
+		Env:       map[string]string{},
+		EnvSource: map[string]string{},
+		Rejected:  map[string]string{},
+	}
+
+	for k, v := range annotations {
+		switch {
+		case k == SkipAnnotation:
+			for _, name := range strings.Split(v, ",") {
+				if name = strings.TrimSpace(name); name != "" {
+					o.Skip[name] = "annotation " + k
+				}
+			}
+		case strings.HasPrefix(k, EnvAnnotationPrefix):
+			name := strings.TrimPrefix(k, EnvAnnotationPrefix)
+			if name == "" {
+				continue
+			}
+			if err := checkEnvVar(name); err != nil {
+				o.Rejected["annotation "+k] = err.Error()
+				continue
+			}
+			o.Env[name] = v
+			o.EnvSource[name] = "annotation " + k
+		}
+	}
+
+	return o
+}
@@ -0,0 +1,97 @@
+package k8s
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestGetOverrides(t *testing.T) {
+	testcases := []struct {
+		name         string
+		annotations  map[string]string
+		wantSkip     map[string]string
+		wantEnv      map[string]string
+		wantRejected []string
+	}{
+		{
+			name:     "no annotations",
+			wantSkip: map[string]string{},
+			wantEnv:  map[string]string{},
+		},
+		{
+			name: "skip and env overrides",
+			annotations: map[string]string{
+				"init.storageos.com/skip":                        " 02-limits, 03-foo,,",
+				"init.storageos.com/env.MINIMUM_MAX_PIDS_LIMIT":  "512",
+				"init.storageos.com/env.":                        "ignored",
+				"other.storageos.com/env.RECOMMENDED_PIDS_LIMIT": "ignored",
+			},
+			wantSkip: map[string]string{
+				"02-limits": "annotation init.storageos.com/skip",
+				"03-foo":    "annotation init.storageos.com/skip",
+			},
+			wantEnv: map[string]string{
+				"MINIMUM_MAX_PIDS_LIMIT": "512",
+			},
+		},
+		{
+			name: "reserved env vars",
+			annotations: map[string]string{
+				"init.storageos.com/env.LD_PRELOAD":       "/tmp/evil.so",
+				"init.storageos.com/env.BASH_ENV":         "/tmp/evil.sh",
+				"init.storageos.com/env.ENV":              "/tmp/evil.sh",
+				"init.storageos.com/env.PATH":             "/tmp",
+				"init.storageos.com/env.HOST_ROOT":        "/tmp",
+				"init.storageos.com/env.INIT_LIB":         "/tmp/evil.sh",
+				"init.storageos.com/env.INIT_RESULT_FILE": "/etc/shadow",
+				"init.storageos.com/env.FOO-BAR":          "invalid",
+				"init.storageos.com/env.FOO":              "bar",
+			},
+			wantSkip: map[string]string{},
+			wantEnv: map[string]string{
+				"FOO": "bar",
+			},
+			wantRejected: []string{"LD_PRELOAD", "BASH_ENV", "ENV", "PATH", "HOST_ROOT", "INIT_LIB", "INIT_RESULT_FILE", "FOO-BAR"},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			client := fake.NewSimpleClientset(&corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "node1",
+					Annotations: tc.annotations,
+				},
+			})
+
+			o, err := NewNodeInfo(client, "node1").GetOverrides()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !reflect.DeepEqual(o.Skip, tc.wantSkip) {
+				t.Errorf("unexpected skip:\n\t(WNT) %v\n\t(GOT) %v", tc.wantSkip, o.Skip)
+			}
+			if !reflect.DeepEqual(o.Env, tc.wantEnv) {
+				t.Errorf("unexpected env:\n\t(WNT) %v\n\t(GOT) %v", tc.wantEnv, o.Env)
+			}
+			if len(o.Rejected) != len(tc.wantRejected) {
+				t.Errorf("unexpected rejected:\n\t(WNT) %v\n\t(GOT) %v", tc.wantRejected, o.Rejected)
+			}
+			for _, name := range tc.wantRejected {
+				if _, ok := o.Rejected["annotation "+EnvAnnotationPrefix+name]; !ok {
+					t.Errorf("expected the %s override to be rejected, got %v", name, o.Rejected)
+				}
+			}
+		})
+	}
+
+	// Unknown Node.
+	if _, err := NewNodeInfo(fake.NewSimpleClientset(), "node1").GetOverrides(); err == nil {
+		t.Error("expected error for unknown node, got none")
+	}
+}
@@ -0,0 +1,63 @@
+package k8s
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/storageos/init/info"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// DefaultScriptsSelector is the default label selector of the ConfigMaps that
+// contain scripts.
+const DefaultScriptsSelector = "init.storageos.com/scripts=true"
+
+// ScriptsInfo implements ScriptsInfoer interface for the k8s ConfigMaps that
+// contain scripts. Each ConfigMap is a script set named after the ConfigMap,
+// with a file per ConfigMap key.
+type ScriptsInfo struct {
+	client    kubernetes.Interface
+	namespace string
+	selector  string
+}
+
+// NewScriptsInfo returns an initialized ScriptsInfo for the ConfigMaps in a
+// namespace matching a label selector.
+func NewScriptsInfo(client kubernetes.Interface, namespace, selector string) *ScriptsInfo {
+	return &ScriptsInfo{
+		client:    client,
+		namespace: namespace,
+		selector:  selector,
+	}
+}
+
+// GetScripts returns the script sets of the selected ConfigMaps, sorted by
+// name.
+func (i *ScriptsInfo) GetScripts() ([]info.ScriptSet, error) {
+	cms, err := i.client.CoreV1().ConfigMaps(i.namespace).List(metav1.ListOptions{LabelSelector: i.selector})
+	if err != nil {
+		return nil, err
+	}
+
+	sets := []info.ScriptSet{}
+	for _, cm := range cms.Items {
+		set := info.ScriptSet{
+			Name:   cm.Name,
+			Source: fmt.Sprintf("configmap %s/%s", cm.Namespace, cm.Name),
+			Files:  map[string][]byte{},
+		}
+		for k, v := range cm.Data {
+			set.Files[k] = []byte(v)
+		}
+		for k, v := range cm.BinaryData {
+			set.Files[k] = v
+		}
+		sets = append(sets, set)
+	}
+
+	sort.Slice(sets, func(a, b int) bool { return sets[a].Name < sets[b].Name })
+
+	return sets, nil
+}
@@ -0,0 +1,62 @@
+package k8s
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/storageos/init/info"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestGetScripts(t *testing.T) {
+	labels := map[string]string{"init.storageos.com/scripts": "true"}
+
+	client := fake.NewSimpleClientset(
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "10-site", Namespace: "kube-system", Labels: labels},
+			Data:       map[string]string{"check.sh": "#!/bin/bash\n"},
+			BinaryData: map[string][]byte{"data.bin": {0, 1}},
+		},
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "05-site", Namespace: "kube-system", Labels: labels},
+			Data:       map[string]string{"other.sh": "#!/bin/bash\n"},
+		},
+		// Not labelled.
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "config", Namespace: "kube-system"},
+			Data:       map[string]string{"foo": "bar"},
+		},
+		// Other namespace.
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "01-other", Namespace: "default", Labels: labels},
+			Data:       map[string]string{"other.sh": "#!/bin/bash\n"},
+		},
+	)
+
+	sets, err := NewScriptsInfo(client, "kube-system", DefaultScriptsSelector).GetScripts()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []info.ScriptSet{
+		{
+			Name:   "05-site",
+			Source: "configmap kube-system/05-site",
+			Files:  map[string][]byte{"other.sh": []byte("#!/bin/bash\n")},
+		},
+		{
+			Name:   "10-site",
+			Source: "configmap kube-system/10-site",
+			Files: map[string][]byte{
+				"check.sh": []byte("#!/bin/bash\n"),
+				"data.bin": {0, 1},
+			},
+		},
+	}
+	if !reflect.DeepEqual(sets, want) {
+		t.Errorf("unexpected script sets:\n\t(WNT) %v\n\t(GOT) %v", want, sets)
+	}
+}
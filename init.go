@@ -1,15 +1,31 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"log"
+	"net/http"
 	"os"
-
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/storageos/init/config"
+	"github.com/storageos/init/host"
 	"github.com/storageos/init/info"
 	"github.com/storageos/init/info/k8s"
+	"github.com/storageos/init/metrics"
+	"github.com/storageos/init/node"
+	"github.com/storageos/init/report"
 	"github.com/storageos/init/script"
+	"github.com/storageos/init/script/lib"
 	"github.com/storageos/init/script/runner"
+	"github.com/storageos/init/watch"
 
 	"k8s.io/client-go/kubernetes"
 	restclient "k8s.io/client-go/rest"
@@ -21,33 +37,262 @@ const (
 	nodeImageEnvVar          = "NODE_IMAGE"
 )
 
+// Paths in the state directory.
+const (
+	// stateReportFile is the file the report of the latest run is written
+	// to.
+	stateReportFile = "report.json"
+	// stateArtifactsDir is the directory the artifacts of the scripts are
+	// preserved in, in a subdirectory per script file.
+	stateArtifactsDir = "artifacts"
+)
+
+// maxTerminationMessage is the size limit of the k8s container termination
+// messages.
+const maxTerminationMessage = 4096
+
+// Subcommands of the init binary, run instead of the scripts.
+const (
+	cmdConfigDump = "config dump"
+	cmdCleanup    = "cleanup"
+	cmdWatch      = "watch"
+	cmdExtract    = "extract"
+)
+
+// commands are the known subcommands.
+var commands = []string{cmdConfigDump, cmdCleanup, cmdWatch, cmdExtract}
+
+// pathList is a flag.Value for a list of paths. The flag can be repeated and
+// each value can contain multiple colon separated paths.
+type pathList []string
+
+// String implements flag.Value.
+func (l *pathList) String() string {
+	return strings.Join(*l, ":")
+}
+
+// Set implements flag.Value.
+func (l *pathList) Set(value string) error {
+	for _, path := range strings.Split(value, ":") {
+		if path != "" {
+			*l = append(*l, path)
+		}
+	}
+	return nil
+}
+
+// stringList is a flag.Value for a list of strings. The flag can be repeated
+// and each value can contain multiple comma separated strings.
+type stringList []string
+
+// String implements flag.Value.
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+// Set implements flag.Value.
+func (l *stringList) Set(value string) error {
+	for _, s := range strings.Split(value, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			*l = append(*l, s)
+		}
+	}
+	return nil
+}
+
+// interpreterMap is a flag.Value for the interpreters of the script files by
+// extension. The flag can be repeated and each value can contain multiple
+// comma separated ext=interpreter mappings, e.g. ".sh=/bin/bash". An empty
+// interpreter removes the mapping of an extension.
+type interpreterMap map[string]string
+
+// String implements flag.Value.
+func (m interpreterMap) String() string {
+	mappings := make([]string, 0, len(m))
+	for ext, interpreter := range m {
+		mappings = append(mappings, ext+"="+interpreter)
+	}
+	sort.Strings(mappings)
+	return strings.Join(mappings, ",")
+}
+
+// Set implements flag.Value.
+func (m interpreterMap) Set(value string) error {
+	for _, mapping := range strings.Split(value, ",") {
+		if mapping = strings.TrimSpace(mapping); mapping == "" {
+			continue
+		}
+		parts := strings.SplitN(mapping, "=", 2)
+		if len(parts) != 2 || !strings.HasPrefix(parts[0], ".") || len(parts[0]) < 2 {
+			return fmt.Errorf("invalid interpreter mapping %q, expected .ext=interpreter", mapping)
+		}
+		if parts[1] == "" {
+			delete(m, parts[0])
+			continue
+		}
+		m[parts[0]] = parts[1]
+	}
+	return nil
+}
+
 func main() {
-	scriptsDir := flag.String("scripts", "", "absolute path of the scripts directory")
+	if err := run(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// run runs the init command and returns the error init fails with. The
+// temporary directories of the scripts and of their library are removed on
+// return.
+func run() error {
+	configFile := flag.String("config", "", "path of the YAML or JSON config file, the flags and env vars take precedence")
+	var scriptsDirs pathList
+	flag.Var(&scriptsDirs, "scripts", "absolute path of the scripts directory, repeat or separate with colons to overlay multiple directories in order, the embedded scripts are run if not set")
+	var onlyScripts, skipScripts stringList
+	flag.Var(&onlyScripts, "only", "run only the scripts matching any of the names, glob patterns or manifest tags, repeat or separate with commas")
+	flag.Var(&skipScripts, "skip", "skip the scripts matching any of the names, glob patterns or manifest tags, repeat or separate with commas")
 	dsName := flag.String("dsName", "", "name of the StorageOS DaemonSet")
 	dsNamespace := flag.String("dsNamespace", "", "namespace of the StorageOS DaemonSet")
 	nodeImage := flag.String("nodeImage", "", "container image of StorageOS Node, use when running out of k8s")
+	logFormat := flag.String("logFormat", string(runner.FormatText), "format of the script output lines, text or json")
+	stripANSI := flag.Bool("stripANSI", false, "remove ANSI escape sequences, e.g. colors, from the script output")
+	captureHeadKB := flag.Int("captureHeadKB", runner.DefaultCaptureHead/1024, "KB of output retained from the start of each script stdout and stderr")
+	captureTailKB := flag.Int("captureTailKB", runner.DefaultCaptureTail/1024, "KB of output retained from the end of each script stdout and stderr")
+	hostRoot := flag.String("hostRoot", host.DefaultRoot, "path where the host root filesystem is mounted")
+	nsenter := flag.String("nsenter", runner.DefaultNsenter, "nsenter binary used to run scripts in the host namespaces")
+	scriptsSelector := flag.String("scriptsConfigMapSelector", "", "label selector of the ConfigMaps in the DaemonSet namespace to load additional scripts from, e.g. "+k8s.DefaultScriptsSelector)
+	nodeName := flag.String("nodeName", "", "name of the k8s Node to publish the init results on, read from NODE_NAME env var if not set")
+	nodeLabels := flag.Bool("nodeLabels", false, "publish the script results as Node labels, e.g. storageos.com/lio=ready")
+	featureLabels := flag.Bool("featureLabels", false, "publish the host features as Node labels, e.g. feature.storageos.com/cgroup-v2=true")
+	verifyScripts := flag.Bool("verifyScripts", false, "verify the scripts directories files against their "+script.ChecksumsFile+" checksums file before running any script")
+	scriptsPublicKey := flag.String("scriptsPublicKey", "", "path of the PEM encoded ed25519 public key to verify the "+script.SignatureFile+" signature of the checksums files, implies -verifyScripts")
+	interpreters := interpreterMap{}
+	for ext, interpreter := range runner.DefaultInterpreters {
+		interpreters[ext] = interpreter
+	}
+	flag.Var(interpreters, "interpreter", "interpreter of the script files that are not executable or lack a shebang by extension, e.g. .py=python3, repeat or separate with commas")
+	keepGoing := flag.Bool("keepGoing", false, "run all the scripts even if some fail, then fail with a summary of all the failures and warnings")
+	terminationGrace := flag.Duration("terminationGrace", runner.DefaultTerminationGrace, "time a script has to exit after a forwarded SIGTERM or SIGINT before it's killed")
+	watchInterval := flag.Duration("watchInterval", watch.DefaultInterval, "interval between two runs of the recheck scripts in watch mode")
+	healthAddr := flag.String("healthAddr", watch.DefaultHealthAddr, "address of the /healthz and /readyz endpoints in watch mode")
+	stateDir := flag.String("stateDir", "", "directory where the report of the latest run and the script artifacts are preserved, e.g. /var/lib/storageos/init, disabled if not set")
+	terminationLog := flag.String("terminationLog", "", "path of the file the run summary is written to, e.g. the container termination message path /dev/termination-log, disabled if not set")
+	metricsFile := flag.String("metricsFile", "", "path of the Prometheus textfile collector file to write the run metrics to, e.g. /var/lib/node_exporter/textfile/storageos-init.prom")
+
+	flag.Usage = usage
+
+	cmd, args, err := parseArgs(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		flag.Usage()
+		return err
+	}
+
+	// Resolve the options from the flags, env vars and config file.
+	cfg := config.New(flag.CommandLine).
+		SetEnv("dsName", daemonSetNameEnvVar).
+		SetEnv("dsNamespace", daemonSetNamespaceEnvVar).
+		SetEnv("nodeName", node.NameEnvVar)
+	if err := cfg.Load(*configFile); err != nil {
+		return fmt.Errorf("failed to load config: %v", err)
+	}
+
+	if cmd == cmdConfigDump {
+		return cfg.Dump(os.Stdout)
+	}
 
-	flag.Parse()
+	// Extract the embedded scripts for inspection, into the directory
+	// argument or a new temporary directory.
+	if cmd == cmdExtract {
+		var dir string
+		if len(args) > 0 {
+			dir = args[0]
+		}
+		if dir == "" {
+			if dir, err = ioutil.TempDir("", "init-scripts"); err != nil {
+				return fmt.Errorf("failed to create scripts dir: %v", err)
+			}
+		}
+		if err := extractScripts(dir); err != nil {
+			return fmt.Errorf("failed to extract the embedded scripts: %v", err)
+		}
+		fmt.Println(dir)
+		return nil
+	}
+
+	// Abort if the script output format is unknown.
+	format, err := runner.ParseFormat(*logFormat)
+	if err != nil {
+		return err
+	}
+
+	// Write the init logs in the same format as the script output lines.
+	if format == runner.FormatJSON {
+		log.SetFlags(0)
+		log.SetOutput(runner.NewLogWriter(os.Stderr))
+	}
+
+	// Abort if the script filters are invalid.
+	filter, err := script.NewFilter(onlyScripts, skipScripts)
+	if err != nil {
+		return err
+	}
 
 	// StorageOS node container image.
 	var storageosImage string
 
-	// Abort if no scripts directory is provided.
-	if *scriptsDir == "" {
-		log.Println("no scripts directory specified, pass scripts dir with -scripts flag.")
-		os.Exit(1)
+	// Create the state directory, where the run report and the script
+	// artifacts are preserved.
+	if *stateDir != "" && cmd != cmdCleanup {
+		if err := os.MkdirAll(*stateDir, 0755); err != nil {
+			return fmt.Errorf("failed to create state dir: %v", err)
+		}
 	}
 
-	// Attempt to get storageos node image.
+	// Remove the temporary scripts and library directories on return.
+	var embeddedDir, configMapScriptsDir, libDir string
+	defer func() {
+		for _, dir := range []string{embeddedDir, configMapScriptsDir, libDir} {
+			if dir != "" {
+				os.RemoveAll(dir)
+			}
+		}
+	}()
 
-	if *nodeImage == "" {
-		var imageInfo info.ImageInfoer
+	// Run the embedded scripts if no scripts directory is provided,
+	// extracted to a private temporary directory.
+	if len(scriptsDirs) == 0 {
+		embeddedDir, err = ioutil.TempDir("", "init-scripts")
+		if err != nil {
+			return fmt.Errorf("failed to create scripts dir: %v", err)
+		}
+		if err := extractScripts(embeddedDir); err != nil {
+			return fmt.Errorf("failed to extract the embedded scripts: %v", err)
+		}
+		log.Println("no scripts directory specified, running the embedded scripts from", embeddedDir)
+		scriptsDirs = pathList{embeddedDir}
+	}
+
+	// Name of the k8s Node to publish the init results on, if any.
+	publishNodeName := getNodeName(*nodeName)
 
-		// This is in k8s environment.
-		kubeclient, err := newK8SClient()
+	// The cleanup mode runs after the StorageOS DaemonSet is removed, the node
+	// image is only known if passed.
+	lookupImage := *nodeImage == "" && cmd != cmdCleanup
+
+	// A k8s client is required to get the node image, load scripts from
+	// ConfigMaps or publish the results.
+	var kubeclient kubernetes.Interface
+	if lookupImage || *scriptsSelector != "" || publishNodeName != "" {
+		kubeclient, err = newK8SClient()
 		if err != nil {
-			log.Fatal(err)
+			return err
 		}
+	}
+
+	// Attempt to get storageos node image.
+
+	if lookupImage {
+		var imageInfo info.ImageInfoer
 
 		// Create a k8s image info.
 		name, namespace := getParamsForK8SImageInfo(*dsName, *dsNamespace)
@@ -56,16 +301,15 @@ func main() {
 		// Get image.
 		storageosImage, err = imageInfo.GetContainerImage(k8s.DefaultContainerName)
 		if err != nil {
-			log.Fatal(err)
+			return err
 		}
 	} else {
 		storageosImage = *nodeImage
 	}
 
 	// Abort if storageos node image is still unknown.
-	if storageosImage == "" {
-		log.Println("unknown storageos node image, pass node image with -nodeImage flag.")
-		os.Exit(1)
+	if storageosImage == "" && cmd != cmdCleanup {
+		return errors.New("unknown storageos node image, pass node image with -nodeImage flag")
 	}
 
 	// scriptEnvVar is the env vars passed to all the scripts.
@@ -73,21 +317,386 @@ func main() {
 
 	scriptEnvVar[nodeImageEnvVar] = storageosImage
 
-	// Get list of all the scripts.
-	allScripts, err := script.GetAllScripts(*scriptsDir)
+	// Pass the host root prefix to the scripts.
+	root := host.NewRoot(*hostRoot)
+	scriptEnvVar[host.EnvVar] = root.Env()
+
+	if release, err := root.KernelRelease(); err != nil {
+		log.Printf("failed to read host kernel release: %v", err)
+	} else {
+		log.Println("host kernel:", release)
+	}
+
+	// Verify the scripts files before listing them, if enabled.
+	verifier, err := getVerifier(*verifyScripts, *scriptsPublicKey)
+	if err != nil {
+		return fmt.Errorf("failed to set up scripts verification: %v", err)
+	}
+	if verifier != nil && embeddedDir != "" {
+		// The embedded scripts are part of the binary.
+		log.Println("the embedded scripts are not verified")
+		verifier = nil
+	}
+
+	// Get list of all the scripts, overlaying the scripts directories.
+	allScripts, err := script.GetOverlayScripts(scriptsDirs, verifier)
 	if err != nil {
-		log.Fatalf("failed to get list of scripts: %v", err)
+		return fmt.Errorf("failed to get list of scripts: %v", err)
+	}
+
+	// Load the additional scripts from ConfigMaps, replacing the scripts with
+	// the same name.
+	if *scriptsSelector != "" {
+		_, namespace := getParamsForK8SImageInfo(*dsName, *dsNamespace)
+		configMapScriptsDir, err = ioutil.TempDir("", "init-configmap-scripts")
+		if err != nil {
+			return fmt.Errorf("failed to create configmap scripts dir: %v", err)
+		}
+		cmScripts, err := getConfigMapScripts(k8s.NewScriptsInfo(kubeclient, namespace, *scriptsSelector), configMapScriptsDir, verifier)
+		if err != nil {
+			return fmt.Errorf("failed to load scripts from configmaps: %v", err)
+		}
+		allScripts = script.Merge(allScripts, cmScripts)
+	}
+
+	// Apply the config file script settings, then the per-node overrides.
+	applyScriptSettings(cfg.Scripts, allScripts)
+
+	if publishNodeName != "" {
+		overrides, err := k8s.NewNodeInfo(kubeclient, publishNodeName).GetOverrides()
+		if err != nil {
+			return fmt.Errorf("failed to get node overrides: %v", err)
+		}
+		applyOverrides(overrides, allScripts, scriptEnvVar)
+	}
+
+	// Skip the scripts filtered out with -only and -skip.
+	for _, pattern := range filter.Apply(allScripts) {
+		log.Printf("filter: pattern %q matches no script", pattern)
+	}
+
+	// In cleanup mode, run the cleanup actions of the scripts instead, in
+	// reverse order.
+	if cmd == cmdCleanup {
+		allScripts, err = script.GetCleanupScripts(allScripts)
+		if err != nil {
+			return fmt.Errorf("failed to get list of cleanup scripts: %v", err)
+		}
 	}
 
 	log.Println("scripts:", allScripts)
 
 	// Create a script runner.
-	run := runner.NewRun()
+	run := runner.NewRun().
+		SetFormat(format).
+		SetStripANSI(*stripANSI).
+		SetCaptureLimits(*captureHeadKB*1024, *captureTailKB*1024).
+		SetHostNamespaces(*nsenter, runner.DefaultNamespaceTarget).
+		SetTerminationGrace(*terminationGrace).
+		SetInterpreters(interpreters)
+
+	// Preserve the script artifacts in the state directory, except the
+	// artifacts of the cleanup actions.
+	if *stateDir != "" && cmd != cmdCleanup {
+		run.SetArtifactsDir(filepath.Join(*stateDir, stateArtifactsDir))
+	}
+
+	// Check that all the scripts can run before running any of them.
+	if err := run.Preflight(allScripts); err != nil {
+		return fmt.Errorf("preflight failed: %v", err)
+	}
+
+	// Install the bash helper library of the scripts.
+	libDir, err = ioutil.TempDir("", "init-lib")
+	if err != nil {
+		return fmt.Errorf("failed to create script library dir: %v", err)
+	}
+	libPath, err := lib.Install(libDir)
+	if err != nil {
+		return fmt.Errorf("failed to install script library: %v", err)
+	}
+	run.SetLibrary(libPath)
+
+	// Forward the termination signals to the running script and reap the
+	// orphaned processes, init is usually the PID 1 of the container.
+	if err := runner.SetChildSubreaper(); err != nil {
+		log.Printf("failed to become child subreaper: %v", err)
+	}
+	stop := make(chan struct{})
+	go handleSignals(run, stop)
+
+	// In watch mode, re-run the recheck scripts periodically until
+	// terminated, publishing the state changes.
+	if cmd == cmdWatch {
+		var publisher *node.Publisher
+		if publishNodeName != "" {
+			publisher = node.NewPublisher(kubeclient, publishNodeName).SetLabels(*nodeLabels)
+		}
+
+		recheckScripts := getRecheckScripts(allScripts)
+		log.Println("recheck scripts:", recheckScripts)
+
+		// The recheck results are merged into the report of the last full
+		// run, so that the report, the metrics and the published state
+		// cover all the scripts.
+		base := readBaseReport(*stateDir)
+
+		w := watch.New(func() *report.Report {
+			recheck := report.New(storageosImage)
+			if err := runScripts(run, recheck, recheckScripts, scriptEnvVar, *keepGoing); err != nil {
+				log.Printf("recheck failed: %v", err)
+			}
+			recheck.Finish()
+			rep := base.Merge(recheck)
+
+			if *metricsFile != "" {
+				if err := metrics.WriteFile(*metricsFile, rep); err != nil {
+					log.Printf("failed to write metrics: %v", err)
+				}
+			}
+			writeReport(*stateDir, rep)
+			return rep
+		}, *watchInterval).SetOnChange(func(rep *report.Report) {
+			log.Printf("recheck state changed, success: %t", rep.Success())
+			if *keepGoing {
+				logSummary(rep)
+			}
+			if publisher == nil {
+				return
+			}
+			if err := publisher.Publish(rep); err != nil {
+				log.Printf("failed to publish results on node %q: %v", publishNodeName, err)
+			}
+			if err := publisher.RecordEvent(rep); err != nil {
+				log.Printf("failed to record event on node %q: %v", publishNodeName, err)
+			}
+		})
+
+		// Stop watching when terminated or when the health endpoints can't
+		// be served.
+		serveErr := make(chan error, 1)
+		go func() {
+			serveErr <- http.ListenAndServe(*healthAddr, w.Handler())
+		}()
+		watchStop := make(chan struct{})
+		watchErr := make(chan error, 1)
+		go func() {
+			select {
+			case <-stop:
+				watchErr <- nil
+			case err := <-serveErr:
+				watchErr <- fmt.Errorf("failed to serve health endpoints: %v", err)
+			}
+			close(watchStop)
+		}()
+
+		w.Run(watchStop)
+		return <-watchErr
+	}
 
 	// Run all the scripts.
-	if err := runScripts(run, allScripts, scriptEnvVar); err != nil {
-		log.Fatalf("init failed: %v", err)
+	rep := report.New(storageosImage)
+	runErr := runScripts(run, rep, allScripts, scriptEnvVar, *keepGoing)
+	rep.Finish()
+	if *keepGoing {
+		logSummary(rep)
+	}
+	writeTerminationLog(*terminationLog, rep)
+
+	// The metrics and the Node results describe the init runs, not the
+	// cleanup.
+	if cmd == cmdCleanup {
+		if runErr != nil {
+			return fmt.Errorf("cleanup failed: %v", runErr)
+		}
+		return nil
 	}
+
+	// Write the run report and metrics.
+	writeReport(*stateDir, rep)
+	if *metricsFile != "" {
+		if err := metrics.WriteFile(*metricsFile, rep); err != nil {
+			log.Printf("failed to write metrics: %v", err)
+		}
+	}
+
+	// Publish the results on the k8s Node.
+	if publishNodeName != "" {
+		publisher := node.NewPublisher(kubeclient, publishNodeName).SetLabels(*nodeLabels)
+		if err := publisher.Publish(rep); err != nil {
+			log.Printf("failed to publish results on node %q: %v", publishNodeName, err)
+		}
+
+		// Discover the host features after the scripts, which may load
+		// kernel modules.
+		if *featureLabels {
+			if features, err := root.Features(); err != nil {
+				log.Printf("failed to discover host features: %v", err)
+			} else if err := publisher.PublishFeatures(features); err != nil {
+				log.Printf("failed to publish features on node %q: %v", publishNodeName, err)
+			}
+		}
+	}
+
+	if runErr != nil {
+		return fmt.Errorf("init failed: %v", runErr)
+	}
+	return nil
+}
+
+// writeReport writes the report of a run to the state directory, if any.
+func writeReport(stateDir string, rep *report.Report) {
+	if stateDir == "" {
+		return
+	}
+	path := filepath.Join(stateDir, stateReportFile)
+	if err := rep.WriteFile(path); err != nil {
+		log.Printf("failed to write report: %v", err)
+		return
+	}
+	log.Println("report written to", path)
+}
+
+// readBaseReport returns the report of the last full run, preserved in the
+// state dir, to merge the recheck results into. It returns an empty report if
+// there is none, and the recheck results are then reported alone.
+func readBaseReport(stateDir string) *report.Report {
+	base := &report.Report{Scripts: []*report.Script{}}
+	if stateDir == "" {
+		log.Println("no state dir, reporting the recheck scripts only")
+		return base
+	}
+	rep, err := report.ReadFile(filepath.Join(stateDir, stateReportFile))
+	if err != nil {
+		log.Printf("failed to read the last full report, reporting the recheck scripts only: %v", err)
+		return base
+	}
+	return rep
+}
+
+// handleSignals forwards SIGTERM and SIGINT to the running script, closing
+// stop on the first one, and reaps the orphaned processes on SIGCHLD.
+func handleSignals(run *runner.Run, stop chan struct{}) {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGTERM, syscall.SIGINT, syscall.SIGCHLD)
+
+	// Reaping waits for the running script to complete, don't block the
+	// termination signals meanwhile.
+	reap := make(chan struct{}, 1)
+	go func() {
+		for range reap {
+			run.Reap()
+		}
+	}()
+
+	for sig := range sigs {
+		if sig == syscall.SIGCHLD {
+			select {
+			case reap <- struct{}{}:
+			default:
+			}
+			continue
+		}
+
+		log.Printf("received %s, interrupting", sig)
+		if run.Interrupted() == 0 {
+			close(stop)
+		}
+		run.Interrupt(sig.(syscall.Signal))
+	}
+}
+
+// logResultDetails logs the details of a script result reported in its
+// result file, if any.
+func logResultDetails(sr *report.Script) {
+	if sr.Message != "" {
+		log.Printf("result: %s: %s: %s", sr.Name, sr.Status, sr.Message)
+	}
+	if sr.Remediation != "" {
+		log.Printf("remediation: %s: %s", sr.Name, sr.Remediation)
+	}
+
+	names := make([]string, 0, len(sr.Facts))
+	for name := range sr.Facts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		log.Printf("fact: %s: %s=%s", sr.Name, name, sr.Facts[name])
+	}
+
+	names = make([]string, 0, len(sr.Metrics))
+	for name := range sr.Metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		log.Printf("metric: %s: %s=%g", sr.Name, name, sr.Metrics[name])
+	}
+
+	names = make([]string, 0, len(sr.Outputs))
+	for name := range sr.Outputs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		log.Printf("output: %s: %s=%s", sr.Name, script.OutputEnvVar(sr.Name, name), sr.Outputs[name])
+	}
+
+	for _, path := range sr.Artifacts {
+		log.Printf("artifact: %s: %s", sr.Name, path)
+	}
+}
+
+// usage prints the usage of the init binary.
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage: %s [command] [flags]\n\n", os.Args[0])
+	fmt.Fprintf(out, "Commands:\n")
+	fmt.Fprintf(out, "  %s\tprint the effective options and where they come from\n", cmdConfigDump)
+	fmt.Fprintf(out, "  %s\t\trun the cleanup actions of the scripts in reverse order\n", cmdCleanup)
+	fmt.Fprintf(out, "  %s\t\tre-run the recheck scripts periodically and serve the health endpoints\n", cmdWatch)
+	fmt.Fprintf(out, "  %s [dir]\twrite the embedded scripts to dir, or a new temporary directory, and print its path\n\n", cmdExtract)
+	fmt.Fprintf(out, "Flags:\n")
+	flag.PrintDefaults()
+}
+
+// parseArgs parses the flags and the subcommand of the command line, and
+// returns the subcommand, if any, and its positional arguments. The flags can
+// be passed before and after the subcommand. Only the extract subcommand takes
+// a positional argument, any other positional argument is an error.
+func parseArgs(fs *flag.FlagSet, args []string) (string, []string, error) {
+	if err := fs.Parse(args); err != nil {
+		return "", nil, err
+	}
+	cmd, rest := getCommand(fs.Args())
+	if cmd != "" {
+		if err := fs.Parse(rest); err != nil {
+			return "", nil, err
+		}
+		rest = fs.Args()
+	}
+
+	maxArgs := 0
+	if cmd == cmdExtract {
+		maxArgs = 1
+	}
+	if len(rest) > maxArgs {
+		return "", nil, fmt.Errorf("unexpected arguments: %s", strings.Join(rest, " "))
+	}
+	return cmd, rest, nil
+}
+
+// getCommand returns the subcommand at the start of the arguments, if any,
+// and the remaining arguments.
+func getCommand(args []string) (string, []string) {
+	for _, cmd := range commands {
+		words := strings.Fields(cmd)
+		if len(args) >= len(words) && strings.Join(args[:len(words)], " ") == cmd {
+			return cmd, args[len(words):]
+		}
+	}
+	return "", args
 }
 
 // NewK8SClient attempts to get k8s cluster configuration and return a new
@@ -127,33 +736,260 @@ func getParamsForK8SImageInfo(dsName, dsNamespace string) (name, namespace strin
 	return dsName, dsNamespace
 }
 
+// scriptEnvVars returns the env vars for a given script, with the outputs of
+// the scripts that ran before it and the script own env vars. Scripts that run
+// in the host namespaces access the host filesystem directly, without the host
+// root prefix.
+func scriptEnvVars(s script.Script, envVars, outputs map[string]string) map[string]string {
+	hostNamespaces := s.Manifest.HostNamespaces && envVars[host.EnvVar] != ""
+	if !hostNamespaces && len(s.Env) == 0 && len(outputs) == 0 {
+		return envVars
+	}
+
+	env := make(map[string]string, len(envVars)+len(outputs)+len(s.Env))
+	for k, v := range envVars {
+		env[k] = v
+	}
+	for k, v := range outputs {
+		env[k] = v
+	}
+	for k, v := range s.Env {
+		env[k] = v
+	}
+	if hostNamespaces {
+		env[host.EnvVar] = ""
+	}
+	return env
+}
+
+// getConfigMapScripts writes the script sets of a scripts info source into a
+// directory and returns the scripts, verified by verifier if not nil.
+func getConfigMapScripts(scriptsInfo info.ScriptsInfoer, dir string, verifier *script.Verifier) ([]script.Script, error) {
+	sets, err := scriptsInfo.GetScripts()
+	if err != nil {
+		return nil, err
+	}
+	for _, set := range sets {
+		log.Printf("scripts from %s", set.Source)
+	}
+	return script.Materialize(dir, sets, verifier)
+}
+
+// getVerifier returns the scripts verifier, or nil if the verification is not
+// enabled. Setting a public key file enables the verification and requires
+// the checksums files to be signed.
+func getVerifier(verify bool, publicKeyFile string) (*script.Verifier, error) {
+	if publicKeyFile == "" {
+		if !verify {
+			return nil, nil
+		}
+		return script.NewVerifier(), nil
+	}
+
+	data, err := ioutil.ReadFile(publicKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	key, err := script.ParsePublicKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key %q: %v", publicKeyFile, err)
+	}
+	return script.NewVerifier().SetPublicKey(key), nil
+}
+
+// getNodeName returns the name of the k8s Node to publish the init results on.
+// If the name is not provided, it's read from the env var set with the
+// downward API. An empty name disables publishing.
+func getNodeName(name string) string {
+	if name == "" {
+		name = os.Getenv(node.NameEnvVar)
+	}
+	return name
+}
+
+// getRecheckScripts returns the scripts to re-run periodically in watch mode.
+func getRecheckScripts(scripts []script.Script) []script.Script {
+	rechecks := []script.Script{}
+	for _, s := range scripts {
+		if s.Manifest.Recheck {
+			rechecks = append(rechecks, s)
+		}
+	}
+	return rechecks
+}
+
+// applyScriptSettings applies the config file settings of the scripts,
+// logging every setting applied. Settings of unknown scripts are logged and
+// ignored.
+func applyScriptSettings(settings map[string]config.ScriptSettings, scripts []script.Script) {
+	found := map[string]bool{}
+	for i, s := range scripts {
+		ss, ok := settings[s.Name]
+		if !ok {
+			continue
+		}
+		found[s.Name] = true
+
+		if ss.Skip {
+			scripts[i].Skip = "skipped by config file"
+			log.Printf("config: skip script %s", s.Name)
+		}
+		if len(ss.Env) > 0 {
+			env := make(map[string]string, len(s.Env)+len(ss.Env))
+			for k, v := range s.Env {
+				env[k] = v
+			}
+			for k, v := range ss.Env {
+				env[k] = v
+				log.Printf("config: script %s env %s=%q", s.Name, k, v)
+			}
+			scripts[i].Env = env
+		}
+	}
+
+	for name := range settings {
+		if !found[name] {
+			log.Printf("config: ignoring settings of unknown script %s", name)
+		}
+	}
+}
+
+// applyOverrides marks the scripts to skip and sets the env var overrides,
+// logging every override applied. The env var overrides replace the config
+// file env vars of the scripts too, they apply after the config file.
+func applyOverrides(o *info.Overrides, scripts []script.Script, envVars map[string]string) {
+	for i, s := range scripts {
+		if source, ok := o.Skip[s.Name]; ok {
+			scripts[i].Skip = fmt.Sprintf("skipped by %s", source)
+			log.Printf("override: skip script %s (%s)", s.Name, source)
+		}
+
+		var env map[string]string
+		for k, v := range o.Env {
+			old, ok := s.Env[k]
+			if !ok {
+				continue
+			}
+			if env == nil {
+				env = make(map[string]string, len(s.Env))
+				for k, v := range s.Env {
+					env[k] = v
+				}
+			}
+			env[k] = v
+			log.Printf("override: script %s env %s=%q, was %q (%s)", s.Name, k, v, old, o.EnvSource[k])
+		}
+		if env != nil {
+			scripts[i].Env = env
+		}
+	}
+
+	for source, reason := range o.Rejected {
+		log.Printf("override: error: rejected %s: %s", source, reason)
+	}
+
+	for k, v := range o.Env {
+		old, ok := envVars[k]
+		if !ok {
+			old = os.Getenv(k)
+		}
+		envVars[k] = v
+		log.Printf("override: env %s=%q, was %q (%s)", k, v, old, o.EnvSource[k])
+	}
+}
+
 // runScripts takes a list of scripts and env vars, and runs the scripts
-// sequentially. The error returned by the script execution is logged as k8s pod
-// event.
+// sequentially, recording each execution in the run report. The error
+// returned by the script execution is logged as k8s pod event.
 // Any preliminary checks that need to be performed before running a script can
 // be performed here.
-func runScripts(run script.Runner, scripts []string, envVars map[string]string) error {
+// It stops at the first failed script, unless keepGoing is set: all the
+// scripts then run and the error is the consolidated summary of the run, with
+// the failures and warnings. It always stops when interrupted.
+func runScripts(run script.Runner, rep *report.Report, scripts []script.Script, envVars map[string]string, keepGoing bool) error {
+	failed := false
 	for _, script := range scripts {
 		// TODO: Check if the script has any preliminary checks to be performed
 		// before execution.
 
-		log.Printf("exec: %s", script)
+		if script.Skip != "" {
+			log.Printf("skip: %s: %s", script, script.Skip)
+			rep.SkipScript(script, script.Skip)
+			continue
+		}
+
+		log.Printf("exec: %s from %s", script, script.Source)
 
-		_, stderr, err := run.RunScript(script, envVars)
+		start := time.Now()
+		result, err := run.RunScript(script, scriptEnvVars(script, envVars, rep.OutputEnvVars()), script.Args...)
+		sr := rep.AddScript(script, start, result, err)
+
+		if sr.Status == report.StatusInterrupted {
+			log.Printf("stop: %s %s", script, sr.Error)
+			return fmt.Errorf("script %q %s", script, sr.Error)
+		}
 
 		// If stderr contains message, log and issue warning event.
-		if len(stderr) > 0 {
+		if result != nil && len(result.Stderr) > 0 {
 			// log.Printf("[STDERR] %s: \n%s\n", script, string(stderr))
 			// Create k8s warning event.
 			// Issue a warning event with the stderr log.
 		}
 
+		logResultDetails(sr)
+
+		var failure error
 		if err != nil {
 			// Create a k8s failure events.
 
-			return fmt.Errorf("script %q failed: %v", script, err)
+			// Describe how the script terminated if it ran.
+			if result != nil {
+				failure = fmt.Errorf("script %q failed: %s", script, result)
+			} else {
+				failure = fmt.Errorf("script %q failed: %v", script, err)
+			}
+		} else if sr.Status == report.StatusFailed {
+			// The script exited successfully but reported a failure.
+			failure = fmt.Errorf("script %q reported failure: %s", script, sr.Error)
+		}
+
+		if failure != nil {
+			if !keepGoing {
+				return failure
+			}
+			log.Printf("fail: %v, keep going", failure)
+			failed = true
+			continue
 		}
+
+		log.Printf("done: %s %s in %s", script, result, result.WallTime)
 	}
 
+	if failed {
+		return errors.New(strings.Join(rep.Summarize(), "; "))
+	}
 	return nil
 }
+
+// logSummary logs the consolidated summary of a finished run.
+func logSummary(rep *report.Report) {
+	for _, line := range rep.Summary {
+		log.Println("summary:", line)
+	}
+}
+
+// writeTerminationLog writes the consolidated summary of a finished run to the
+// termination log of the container, if any, truncated to the size limit of
+// the termination messages.
+func writeTerminationLog(path string, rep *report.Report) {
+	if path == "" {
+		return
+	}
+	msg := strings.Join(rep.Summary, "\n") + "\n"
+	if len(msg) > maxTerminationMessage {
+		msg = msg[:maxTerminationMessage]
+	}
+	if err := ioutil.WriteFile(path, []byte(msg), 0644); err != nil {
+		log.Printf("failed to write termination log: %v", err)
+	}
+}
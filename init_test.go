@@ -2,13 +2,27 @@ package main
 
 import (
 	"errors"
+	"flag"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 
+	"github.com/storageos/init/config"
+	"github.com/storageos/init/host"
+	"github.com/storageos/init/info"
 	"github.com/storageos/init/info/k8s"
 	"github.com/storageos/init/mocks"
+	"github.com/storageos/init/node"
+	"github.com/storageos/init/report"
+	"github.com/storageos/init/script"
 
 	"github.com/golang/mock/gomock"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
 )
 
 func TestGetParamsForK8SImageInfo(t *testing.T) {
@@ -77,17 +91,92 @@ func TestGetParamsForK8SImageInfo(t *testing.T) {
 	}
 }
 
+func TestPathList(t *testing.T) {
+	var l pathList
+	for _, value := range []string{"/scripts", "/site:/node:", ""} {
+		if err := l.Set(value); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	want := pathList{"/scripts", "/site", "/node"}
+	if !reflect.DeepEqual(l, want) {
+		t.Errorf("unexpected paths:\n\t(WNT) %v\n\t(GOT) %v", want, l)
+	}
+	if l.String() != "/scripts:/site:/node" {
+		t.Errorf("unexpected string:\n\t(WNT) %s\n\t(GOT) %s", "/scripts:/site:/node", l.String())
+	}
+}
+
+func TestStringList(t *testing.T) {
+	var l stringList
+	for _, value := range []string{"02-limits", "kernel, 0*-lio,", ""} {
+		if err := l.Set(value); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	want := stringList{"02-limits", "kernel", "0*-lio"}
+	if !reflect.DeepEqual(l, want) {
+		t.Errorf("unexpected strings:\n\t(WNT) %v\n\t(GOT) %v", want, l)
+	}
+	if l.String() != "02-limits,kernel,0*-lio" {
+		t.Errorf("unexpected string:\n\t(WNT) %s\n\t(GOT) %s", "02-limits,kernel,0*-lio", l.String())
+	}
+}
+
+func TestGetNodeName(t *testing.T) {
+	testcases := []struct {
+		name     string
+		nodeVar  string
+		envvar   string
+		wantName string
+	}{
+		{
+			name:     "flag variable",
+			nodeVar:  "node1",
+			envvar:   "node2",
+			wantName: "node1",
+		},
+		{
+			name:     "env variable",
+			envvar:   "node2",
+			wantName: "node2",
+		},
+		{
+			name: "not set",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.envvar != "" {
+				os.Setenv(node.NameEnvVar, tc.envvar)
+				defer os.Unsetenv(node.NameEnvVar)
+			}
+
+			if name := getNodeName(tc.nodeVar); name != tc.wantName {
+				t.Errorf("unexpected node name:\n\t(WNT) %s\n\t(GOT) %s", tc.wantName, name)
+			}
+		})
+	}
+}
+
 func TestRunScript(t *testing.T) {
 	testcases := []struct {
-		name    string
-		scripts []string
-		envvars map[string]string
-		retErr  error
-		wantErr bool
+		name      string
+		scripts   []script.Script
+		envvars   map[string]string
+		retCode   int
+		retErr    error
+		keepGoing bool
+		calls     int
+		reported  int
+		wantErr   bool
 	}{
 		{
 			name:    "simple run",
-			scripts: []string{"script1", "script2", "script3"},
+			scripts: []script.Script{{Path: "script1"}, {Path: "script2"}, {Path: "script3"}},
 			envvars: map[string]string{
 				"FOO": "val1",
 				"BAR": "val2",
@@ -95,13 +184,44 @@ func TestRunScript(t *testing.T) {
 		},
 		{
 			name:    "error run",
-			scripts: []string{"sc1"},
+			scripts: []script.Script{{Path: "sc1"}},
+			retCode: 1,
 			retErr:  errors.New("some-error"),
 			wantErr: true,
 		},
+		{
+			name:      "keep going run",
+			scripts:   []script.Script{{Path: "sc1"}, {Path: "sc2"}, {Path: "sc3"}},
+			retCode:   1,
+			retErr:    errors.New("some-error"),
+			keepGoing: true,
+			wantErr:   true,
+		},
+		{
+			name:      "interrupted keep going run",
+			scripts:   []script.Script{{Path: "sc1"}, {Path: "sc2"}},
+			retCode:   -1,
+			retErr:    script.ErrInterrupted,
+			keepGoing: true,
+			calls:     1,
+			reported:  1,
+			wantErr:   true,
+		},
+		{
+			name:    "interrupted run",
+			scripts: []script.Script{{Path: "sc1"}},
+			retCode: -1,
+			retErr:  script.ErrInterrupted,
+			wantErr: true,
+		},
 		{
 			name: "no script",
 		},
+		{
+			name:    "skipped script",
+			scripts: []script.Script{{Path: "sc1", Skip: "skipped by test"}, {Path: "sc2"}},
+			calls:   1,
+		},
 	}
 
 	for _, tc := range testcases {
@@ -111,20 +231,504 @@ func TestRunScript(t *testing.T) {
 
 			mockRunner := mocks.NewMockRunner(mockCtrl)
 
+			// All the scripts run unless the number of calls is set.
+			calls := len(tc.scripts)
+			if tc.calls > 0 {
+				calls = tc.calls
+			}
+
 			// Returned error is tc.retErr. Avoid adding multiple scripts when
 			// tc.retErr is set. All the calls will return an error. This will
 			// result in unexpected number of times the function is called
 			// because the first error will end the parent runScript function.
 			mockRunner.EXPECT().
 				RunScript(gomock.Any(), tc.envvars).
-				Return(nil, nil, tc.retErr).
-				Times(len(tc.scripts))
+				Return(&script.Result{ExitCode: tc.retCode}, tc.retErr).
+				Times(calls)
 
-			if err := runScripts(mockRunner, tc.scripts, tc.envvars); err != nil {
-				if !tc.wantErr {
-					t.Errorf("unexpected error while running scripts: %v", err)
-				}
+			rep := report.New("")
+			err := runScripts(mockRunner, rep, tc.scripts, tc.envvars, tc.keepGoing)
+			if (err != nil) != tc.wantErr {
+				t.Errorf("unexpected error while running scripts: %v", err)
+			}
+
+			// All the executed and skipped scripts must be recorded in the
+			// report, unless the run stopped.
+			reported := len(tc.scripts)
+			if tc.reported > 0 {
+				reported = tc.reported
+			}
+			if len(rep.Scripts) != reported {
+				t.Errorf("unexpected number of reported scripts:\n\t(WNT) %d\n\t(GOT) %d", reported, len(rep.Scripts))
+			}
+			if rep.Success() == tc.wantErr {
+				t.Errorf("unexpected report success: %t", rep.Success())
 			}
 		})
 	}
 }
+
+func TestRunScriptsSummary(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockRunner := mocks.NewMockRunner(mockCtrl)
+	envvars := map[string]string{}
+
+	gomock.InOrder(
+		mockRunner.EXPECT().RunScript(gomock.Any(), envvars).
+			Return(&script.Result{ExitCode: 1, Record: &script.Record{Remediation: "modprobe tcm_loop"}}, errors.New("exit status 1")),
+		mockRunner.EXPECT().RunScript(gomock.Any(), envvars).
+			Return(&script.Result{Record: &script.Record{Status: script.RecordWarning, Message: "low max pids"}}, nil),
+	)
+
+	scripts := []script.Script{{Path: "lio.sh", Name: "01-lio"}, {Path: "limits.sh", Name: "02-limits"}}
+	rep := report.New("")
+	err := runScripts(mockRunner, rep, scripts, envvars, true)
+
+	// The error is the summary of the run, with the failures and warnings.
+	want := "2 scripts, 1 warning, 1 failed; failed: 01-lio: exited 1 (remediation: modprobe tcm_loop); warning: 02-limits: low max pids"
+	if err == nil || err.Error() != want {
+		t.Fatalf("unexpected error:\n\t(WNT) %s\n\t(GOT) %v", want, err)
+	}
+
+	// The finished report and the termination log contain the summary.
+	rep.Finish()
+	wantSummary := []string{
+		"2 scripts, 1 warning, 1 failed",
+		"failed: 01-lio: exited 1 (remediation: modprobe tcm_loop)",
+		"warning: 02-limits: low max pids",
+	}
+	if !reflect.DeepEqual(rep.Summary, wantSummary) {
+		t.Errorf("unexpected summary:\n\t(WNT) %q\n\t(GOT) %q", wantSummary, rep.Summary)
+	}
+
+	path := filepath.Join(t.TempDir(), "termination-log")
+	writeTerminationLog(path, rep)
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read termination log: %v", err)
+	}
+	if wantLog := strings.Join(wantSummary, "\n") + "\n"; string(data) != wantLog {
+		t.Errorf("unexpected termination log:\n\t(WNT) %q\n\t(GOT) %q", wantLog, data)
+	}
+}
+
+func TestApplyOverrides(t *testing.T) {
+	configEnv := map[string]string{"MINIMUM_MAX_PIDS_LIMIT": "2048", "BAR": "baz"}
+	scripts := []script.Script{
+		{Name: "01-lio"},
+		{Name: "02-limits", Env: configEnv},
+	}
+	envvars := map[string]string{
+		"MINIMUM_MAX_PIDS_LIMIT": "1024",
+	}
+
+	o := &info.Overrides{
+		Skip: map[string]string{"02-limits": "annotation skip", "03-foo": "annotation skip"},
+		Env:  map[string]string{"MINIMUM_MAX_PIDS_LIMIT": "512", "FOO": "bar"},
+	}
+	applyOverrides(o, scripts, envvars)
+
+	if scripts[0].Skip != "" {
+		t.Errorf("unexpected skip of %s: %s", scripts[0].Name, scripts[0].Skip)
+	}
+	if scripts[1].Skip == "" {
+		t.Errorf("expected skip of %s", scripts[1].Name)
+	}
+
+	wantVars := map[string]string{
+		"MINIMUM_MAX_PIDS_LIMIT": "512",
+		"FOO":                    "bar",
+	}
+	if !reflect.DeepEqual(envvars, wantVars) {
+		t.Errorf("unexpected env vars:\n\t(WNT) %v\n\t(GOT) %v", wantVars, envvars)
+	}
+
+	// The overrides replace the config file env vars of the scripts, without
+	// changing the config file settings.
+	wantScriptVars := map[string]string{
+		"MINIMUM_MAX_PIDS_LIMIT": "512",
+		"BAR":                    "baz",
+	}
+	if !reflect.DeepEqual(scripts[1].Env, wantScriptVars) {
+		t.Errorf("unexpected script env vars:\n\t(WNT) %v\n\t(GOT) %v", wantScriptVars, scripts[1].Env)
+	}
+	if configEnv["MINIMUM_MAX_PIDS_LIMIT"] != "2048" {
+		t.Errorf("unexpected change of the config file env vars: %v", configEnv)
+	}
+	if scripts[0].Env != nil {
+		t.Errorf("unexpected env vars of %s: %v", scripts[0].Name, scripts[0].Env)
+	}
+}
+
+func TestGetConfigMapScripts(t *testing.T) {
+	client := fake.NewSimpleClientset(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "03-site",
+			Namespace: "kube-system",
+			Labels:    map[string]string{"init.storageos.com/scripts": "true"},
+		},
+		Data: map[string]string{"check.sh": "#!/bin/bash\n"},
+	})
+
+	dir, err := ioutil.TempDir("", "init-configmap-test")
+	if err != nil {
+		t.Fatalf("failed to create directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	scripts, err := getConfigMapScripts(k8s.NewScriptsInfo(client, "kube-system", k8s.DefaultScriptsSelector), dir, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []script.Script{
+		{
+			Path:    filepath.Join(dir, "03-site", "check.sh"),
+			RelPath: filepath.Join("03-site", "check.sh"),
+			Name:    "03-site",
+			Source:  "configmap kube-system/03-site",
+		},
+	}
+	if !reflect.DeepEqual(scripts, want) {
+		t.Errorf("unexpected scripts:\n\t(WNT) %+v\n\t(GOT) %+v", want, scripts)
+	}
+}
+
+func TestApplyScriptSettings(t *testing.T) {
+	scripts := []script.Script{
+		{Name: "01-lio"},
+		{Name: "02-limits", Env: map[string]string{"FOO": "bar"}},
+	}
+
+	settings := map[string]config.ScriptSettings{
+		"01-lio":    {Skip: true},
+		"02-limits": {Env: map[string]string{"MINIMUM_MAX_PIDS_LIMIT": "512"}},
+		"03-foo":    {Skip: true},
+	}
+	applyScriptSettings(settings, scripts)
+
+	if scripts[0].Skip == "" {
+		t.Errorf("expected skip of %s", scripts[0].Name)
+	}
+	if scripts[1].Skip != "" {
+		t.Errorf("unexpected skip of %s: %s", scripts[1].Name, scripts[1].Skip)
+	}
+
+	wantVars := map[string]string{
+		"FOO":                    "bar",
+		"MINIMUM_MAX_PIDS_LIMIT": "512",
+	}
+	if !reflect.DeepEqual(scripts[1].Env, wantVars) {
+		t.Errorf("unexpected env vars:\n\t(WNT) %v\n\t(GOT) %v", wantVars, scripts[1].Env)
+	}
+}
+
+func TestGetRecheckScripts(t *testing.T) {
+	scripts := []script.Script{
+		{Name: "01-lio", Manifest: script.Manifest{Recheck: true}},
+		{Name: "02-limits"},
+		{Name: "03-mounts", Manifest: script.Manifest{Recheck: true}},
+	}
+
+	rechecks := getRecheckScripts(scripts)
+	if len(rechecks) != 2 || rechecks[0].Name != "01-lio" || rechecks[1].Name != "03-mounts" {
+		t.Errorf("unexpected recheck scripts: %v", rechecks)
+	}
+}
+
+func TestGetCommand(t *testing.T) {
+	testcases := []struct {
+		name     string
+		args     []string
+		wantCmd  string
+		wantArgs []string
+	}{
+		{
+			name:     "no command",
+			args:     []string{"-scripts=/scripts"},
+			wantArgs: []string{"-scripts=/scripts"},
+		},
+		{
+			name:     "config dump",
+			args:     []string{"config", "dump", "-config=/etc/init.yaml"},
+			wantCmd:  cmdConfigDump,
+			wantArgs: []string{"-config=/etc/init.yaml"},
+		},
+		{
+			name:     "cleanup",
+			args:     []string{"cleanup", "-scripts=/scripts"},
+			wantCmd:  cmdCleanup,
+			wantArgs: []string{"-scripts=/scripts"},
+		},
+		{
+			name:     "watch",
+			args:     []string{"watch"},
+			wantCmd:  cmdWatch,
+			wantArgs: []string{},
+		},
+		{
+			name:     "extract",
+			args:     []string{"extract", "/tmp/scripts"},
+			wantCmd:  cmdExtract,
+			wantArgs: []string{"/tmp/scripts"},
+		},
+		{
+			name:     "incomplete command",
+			args:     []string{"config"},
+			wantArgs: []string{"config"},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			cmd, args := getCommand(tc.args)
+			if cmd != tc.wantCmd {
+				t.Errorf("unexpected command:\n\t(WNT) %s\n\t(GOT) %s", tc.wantCmd, cmd)
+			}
+			if !reflect.DeepEqual(args, tc.wantArgs) {
+				t.Errorf("unexpected args:\n\t(WNT) %v\n\t(GOT) %v", tc.wantArgs, args)
+			}
+		})
+	}
+}
+
+func TestParseArgs(t *testing.T) {
+	testcases := []struct {
+		name        string
+		args        []string
+		wantCmd     string
+		wantArgs    []string
+		wantScripts string
+		wantErr     bool
+	}{
+		{
+			name:     "no command",
+			args:     []string{"-scripts=/scripts"},
+			wantArgs: []string{},
+		},
+		{
+			name:        "flags before the command",
+			args:        []string{"-scripts=/scripts", "cleanup"},
+			wantCmd:     cmdCleanup,
+			wantArgs:    []string{},
+			wantScripts: "/scripts",
+		},
+		{
+			name:        "flags after the command",
+			args:        []string{"config", "dump", "-scripts=/scripts"},
+			wantCmd:     cmdConfigDump,
+			wantArgs:    []string{},
+			wantScripts: "/scripts",
+		},
+		{
+			name:        "extract with dir",
+			args:        []string{"-scripts=/scripts", "extract", "/tmp/scripts"},
+			wantCmd:     cmdExtract,
+			wantArgs:    []string{"/tmp/scripts"},
+			wantScripts: "/scripts",
+		},
+		{
+			name:     "extract without dir",
+			args:     []string{"extract"},
+			wantCmd:  cmdExtract,
+			wantArgs: []string{},
+		},
+		{
+			name:    "extract with two dirs",
+			args:    []string{"extract", "/tmp/a", "/tmp/b"},
+			wantErr: true,
+		},
+		{
+			name:    "unknown command",
+			args:    []string{"-scripts=/scripts", "run"},
+			wantErr: true,
+		},
+		{
+			name:    "command with argument",
+			args:    []string{"watch", "now"},
+			wantErr: true,
+		},
+		{
+			name:    "unknown flag",
+			args:    []string{"cleanup", "-foo"},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			fs := flag.NewFlagSet("init", flag.ContinueOnError)
+			fs.SetOutput(ioutil.Discard)
+			scripts := fs.String("scripts", "", "")
+
+			cmd, args, err := parseArgs(fs, tc.args)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tc.wantErr {
+				return
+			}
+			if cmd != tc.wantCmd {
+				t.Errorf("unexpected command:\n\t(WNT) %s\n\t(GOT) %s", tc.wantCmd, cmd)
+			}
+			if !reflect.DeepEqual(args, tc.wantArgs) {
+				t.Errorf("unexpected args:\n\t(WNT) %v\n\t(GOT) %v", tc.wantArgs, args)
+			}
+			if tc.wantScripts != "" && *scripts != tc.wantScripts {
+				t.Errorf("unexpected scripts flag:\n\t(WNT) %s\n\t(GOT) %s", tc.wantScripts, *scripts)
+			}
+		})
+	}
+}
+
+func TestRunScriptOutputs(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockRunner := mocks.NewMockRunner(mockCtrl)
+	envvars := map[string]string{"FOO": "bar"}
+
+	gomock.InOrder(
+		mockRunner.EXPECT().
+			RunScript(gomock.Any(), envvars).
+			Return(&script.Result{Record: &script.Record{Outputs: map[string]string{"LIO_USER_BACKSTORE": "available"}}}, nil),
+		mockRunner.EXPECT().
+			RunScript(gomock.Any(), map[string]string{"FOO": "bar", "INIT_OUTPUT_01_LIO_LIO_USER_BACKSTORE": "available"}).
+			Return(&script.Result{}, nil),
+	)
+
+	rep := report.New("")
+	scripts := []script.Script{{Name: "01-lio", Path: "lio.sh"}, {Name: "02-limits", Path: "limits.sh"}}
+	if err := runScripts(mockRunner, rep, scripts, envvars, false); err != nil {
+		t.Fatalf("unexpected error while running scripts: %v", err)
+	}
+
+	// The shared env vars must not change.
+	if len(envvars) != 1 {
+		t.Errorf("shared env vars modified: %v", envvars)
+	}
+}
+
+func TestScriptEnvVars(t *testing.T) {
+	testcases := []struct {
+		name     string
+		script   script.Script
+		envvars  map[string]string
+		outputs  map[string]string
+		wantVars map[string]string
+	}{
+		{
+			name:     "container script",
+			script:   script.Script{Path: "foo.sh"},
+			envvars:  map[string]string{host.EnvVar: "/host", "FOO": "bar"},
+			wantVars: map[string]string{host.EnvVar: "/host", "FOO": "bar"},
+		},
+		{
+			name: "host namespaces script",
+			script: script.Script{
+				Path:     "foo.sh",
+				Manifest: script.Manifest{HostNamespaces: true},
+			},
+			envvars:  map[string]string{host.EnvVar: "/host", "FOO": "bar"},
+			wantVars: map[string]string{host.EnvVar: "", "FOO": "bar"},
+		},
+		{
+			name: "script env vars",
+			script: script.Script{
+				Path: "foo.sh",
+				Env:  map[string]string{"FOO": "baz", "BAR": "qux"},
+			},
+			envvars:  map[string]string{host.EnvVar: "/host", "FOO": "bar"},
+			wantVars: map[string]string{host.EnvVar: "/host", "FOO": "baz", "BAR": "qux"},
+		},
+		{
+			name: "outputs of previous scripts",
+			script: script.Script{
+				Path: "foo.sh",
+				Env:  map[string]string{"INIT_OUTPUT_01_LIO_BAR": "qux"},
+			},
+			envvars:  map[string]string{"FOO": "bar"},
+			outputs:  map[string]string{"INIT_OUTPUT_01_LIO_FOO": "baz", "INIT_OUTPUT_01_LIO_BAR": "baz"},
+			wantVars: map[string]string{"FOO": "bar", "INIT_OUTPUT_01_LIO_FOO": "baz", "INIT_OUTPUT_01_LIO_BAR": "qux"},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := scriptEnvVars(tc.script, tc.envvars, tc.outputs)
+			if !reflect.DeepEqual(got, tc.wantVars) {
+				t.Errorf("unexpected env vars:\n\t(WNT) %v\n\t(GOT) %v", tc.wantVars, got)
+			}
+		})
+	}
+
+	// The env vars of other scripts must not change.
+	envvars := map[string]string{host.EnvVar: "/host"}
+	scriptEnvVars(script.Script{Manifest: script.Manifest{HostNamespaces: true}}, envvars, nil)
+	if envvars[host.EnvVar] != "/host" {
+		t.Errorf("shared env vars modified: %v", envvars)
+	}
+}
+
+func TestExtractScripts(t *testing.T) {
+	dir, err := ioutil.TempDir("", "init-embedded-test")
+	if err != nil {
+		t.Fatalf("failed to create directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := extractScripts(dir); err != nil {
+		t.Fatalf("failed to extract the embedded scripts: %v", err)
+	}
+
+	// The extracted scripts must match the stock scripts.
+	scripts, err := script.GetAllScripts(dir)
+	if err != nil {
+		t.Fatalf("failed to get the extracted scripts: %v", err)
+	}
+	stockScripts, err := script.GetAllScripts(embeddedScriptsDir)
+	if err != nil {
+		t.Fatalf("failed to get the stock scripts: %v", err)
+	}
+	if len(scripts) != len(stockScripts) {
+		t.Fatalf("unexpected number of scripts:\n\t(WNT) %d\n\t(GOT) %d", len(stockScripts), len(scripts))
+	}
+	for i, s := range scripts {
+		stock := stockScripts[i]
+		if s.RelPath != stock.RelPath || !reflect.DeepEqual(s.Manifest, stock.Manifest) {
+			t.Errorf("unexpected script:\n\t(WNT) %s %+v\n\t(GOT) %s %+v", stock.RelPath, stock.Manifest, s.RelPath, s.Manifest)
+		}
+		info, err := os.Stat(s.Path)
+		if err != nil {
+			t.Fatalf("failed to stat script: %v", err)
+		}
+		if info.Mode()&0111 == 0 {
+			t.Errorf("script %s not executable: %s", s.RelPath, info.Mode())
+		}
+	}
+}
+
+func TestInterpreterMap(t *testing.T) {
+	m := interpreterMap{".sh": "/bin/bash", ".py": "python3"}
+	for _, value := range []string{".py=/usr/bin/python3.9", ".rb=ruby, .sh=", ""} {
+		if err := m.Set(value); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	want := interpreterMap{".py": "/usr/bin/python3.9", ".rb": "ruby"}
+	if !reflect.DeepEqual(m, want) {
+		t.Errorf("unexpected interpreters:\n\t(WNT) %v\n\t(GOT) %v", want, m)
+	}
+	if m.String() != ".py=/usr/bin/python3.9,.rb=ruby" {
+		t.Errorf("unexpected string:\n\t(WNT) %s\n\t(GOT) %s", ".py=/usr/bin/python3.9,.rb=ruby", m.String())
+	}
+
+	for _, value := range []string{"sh=/bin/bash", ".=bash", ".sh"} {
+		if err := m.Set(value); err == nil {
+			t.Errorf("expected an error for %q", value)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+// Package metrics writes the metrics of an init run in the Prometheus text
+// format, to be collected by the node-exporter textfile collector.
+package metrics
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/storageos/init/fileutil"
+	"github.com/storageos/init/report"
+)
+
+// Metric names.
+const (
+	InfoMetric            = "storageos_init_info"
+	LastRunMetric         = "storageos_init_last_run_timestamp"
+	LastRunSuccessMetric  = "storageos_init_last_run_success"
+	LastRunDurationMetric = "storageos_init_last_run_duration_seconds"
+	RunsMetric            = "storageos_init_runs_total"
+	ScriptDurationMetric  = "storageos_init_script_duration_seconds"
+	ScriptResultMetric    = "storageos_init_script_result"
+	ScriptMetric          = "storageos_init_script_metric"
+)
+
+// Run results of the runs counter.
+const (
+	runResultSuccess = "success"
+	runResultFailure = "failure"
+)
+
+// runsLine matches the runs counter lines of a previously written metrics
+// file.
+var runsLine = regexp.MustCompile(`^` + RunsMetric + `\{result="([a-z]+)"\} ([0-9]+)$`)
+
+// WriteFile writes the metrics of a run report to a textfile collector file.
+// The runs counter is carried over from the existing file, if any. The file is
+// written atomically, so that the collector never reads a partial file.
+func WriteFile(path string, r *report.Report) error {
+	runs, err := readRuns(path)
+	if err != nil {
+		return err
+	}
+
+	result := runResultFailure
+	if r.Success() {
+		result = runResultSuccess
+	}
+	runs[result]++
+
+	return fileutil.WriteFileAtomic(path, Format(r, runs), 0644)
+}
+
+// readRuns reads the runs counter from an existing metrics file.
+func readRuns(path string) (map[string]int64, error) {
+	runs := map[string]int64{
+		runResultSuccess: 0,
+		runResultFailure: 0,
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return runs, nil
+		}
+		return nil, err
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		m := runsLine.FindStringSubmatch(scanner.Text())
+		if m == nil {
+			continue
+		}
+		n, err := strconv.ParseInt(m[2], 10, 64)
+		if err != nil {
+			continue
+		}
+		runs[m[1]] = n
+	}
+
+	return runs, scanner.Err()
+}
+
+// Format returns the metrics of a run report and the runs counter in the
+// Prometheus text format. The script samples are labelled with the script
+// name and its relative path, as the scripts of a directory share the name.
+func Format(r *report.Report, runs map[string]int64) []byte {
+	var b bytes.Buffer
+
+	writeHeader(&b, InfoMetric, "gauge", "Information about the StorageOS init run.")
+	writeSample(&b, InfoMetric, labels("node_image", r.NodeImage), 1)
+
+	writeHeader(&b, LastRunMetric, "gauge", "Unix timestamp of the end of the last init run.")
+	writeSample(&b, LastRunMetric, labels("node_image", r.NodeImage), float64(r.End.UnixNano())/1e9)
+
+	success := 0.0
+	if r.Success() {
+		success = 1
+	}
+	writeHeader(&b, LastRunSuccessMetric, "gauge", "Whether the last init run succeeded.")
+	writeSample(&b, LastRunSuccessMetric, "", success)
+
+	writeHeader(&b, LastRunDurationMetric, "gauge", "Duration of the last init run in seconds.")
+	writeSample(&b, LastRunDurationMetric, "", r.End.Sub(r.Start).Seconds())
+
+	results := make([]string, 0, len(runs))
+	for result := range runs {
+		results = append(results, result)
+	}
+	sort.Strings(results)
+	writeHeader(&b, RunsMetric, "counter", "Total number of init runs by result.")
+	for _, result := range results {
+		writeSample(&b, RunsMetric, labels("result", result), float64(runs[result]))
+	}
+
+	writeHeader(&b, ScriptDurationMetric, "gauge", "Duration of the last execution of the init script in seconds.")
+	for _, s := range r.Scripts {
+		writeSample(&b, ScriptDurationMetric, labels("script", s.Name, "path", s.RelPath), s.Duration.Seconds())
+	}
+
+	writeHeader(&b, ScriptResultMetric, "gauge", "Result of the last execution of the init script, 1 for the current result.")
+	for _, s := range r.Scripts {
+		for _, status := range report.Statuses {
+			value := 0.0
+			if s.Status == status {
+				value = 1
+			}
+			writeSample(&b, ScriptResultMetric, labels("script", s.Name, "path", s.RelPath, "result", string(status)), value)
+		}
+	}
+
+	writeHeader(&b, ScriptMetric, "gauge", "Value measured by the init script, reported in its result file.")
+	for _, s := range r.Scripts {
+		names := make([]string, 0, len(s.Metrics))
+		for name := range s.Metrics {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			writeSample(&b, ScriptMetric, labels("script", s.Name, "path", s.RelPath, "name", name), s.Metrics[name])
+		}
+	}
+
+	return b.Bytes()
+}
+
+// writeHeader writes the HELP and TYPE lines of a metric.
+func writeHeader(b *bytes.Buffer, name, typ, help string) {
+	fmt.Fprintf(b, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(b, "# TYPE %s %s\n", name, typ)
+}
+
+// writeSample writes a sample line of a metric.
+func writeSample(b *bytes.Buffer, name, labels string, value float64) {
+	fmt.Fprintf(b, "%s%s %s\n", name, labels, strconv.FormatFloat(value, 'g', -1, 64))
+}
+
+// labelEscaper escapes the label values.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// labels formats label name and value pairs.
+func labels(pairs ...string) string {
+	parts := make([]string, 0, len(pairs)/2)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		parts = append(parts, fmt.Sprintf(`%s="%s"`, pairs[i], labelEscaper.Replace(pairs[i+1])))
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
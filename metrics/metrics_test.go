@@ -0,0 +1,132 @@
+package metrics
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/storageos/init/report"
+)
+
+// testReport returns a run report with a passed and a failed script.
+func testReport() *report.Report {
+	start := time.Unix(1600000000, 0)
+	return &report.Report{
+		NodeImage: "storageos/node:test",
+		Start:     start,
+		End:       start.Add(3 * time.Second),
+		Scripts: []*report.Script{
+			{Name: "01-lio", RelPath: "01-lio/enable-lio.sh", Status: report.StatusPassed, Duration: 2 * time.Second, Metrics: map[string]float64{"modules_loaded": 5, "load_seconds": 0.25}},
+			{Name: "02-limits", RelPath: "02-limits/limits.sh", Status: report.StatusFailed, Duration: 500 * time.Millisecond},
+		},
+	}
+}
+
+func TestFormat(t *testing.T) {
+	want := `# HELP storageos_init_info Information about the StorageOS init run.
+# TYPE storageos_init_info gauge
+storageos_init_info{node_image="storageos/node:test"} 1
+# HELP storageos_init_last_run_timestamp Unix timestamp of the end of the last init run.
+# TYPE storageos_init_last_run_timestamp gauge
+storageos_init_last_run_timestamp{node_image="storageos/node:test"} 1.600000003e+09
+# HELP storageos_init_last_run_success Whether the last init run succeeded.
+# TYPE storageos_init_last_run_success gauge
+storageos_init_last_run_success 0
+# HELP storageos_init_last_run_duration_seconds Duration of the last init run in seconds.
+# TYPE storageos_init_last_run_duration_seconds gauge
+storageos_init_last_run_duration_seconds 3
+# HELP storageos_init_runs_total Total number of init runs by result.
+# TYPE storageos_init_runs_total counter
+storageos_init_runs_total{result="failure"} 2
+storageos_init_runs_total{result="success"} 5
+# HELP storageos_init_script_duration_seconds Duration of the last execution of the init script in seconds.
+# TYPE storageos_init_script_duration_seconds gauge
+storageos_init_script_duration_seconds{script="01-lio",path="01-lio/enable-lio.sh"} 2
+storageos_init_script_duration_seconds{script="02-limits",path="02-limits/limits.sh"} 0.5
+# HELP storageos_init_script_result Result of the last execution of the init script, 1 for the current result.
+# TYPE storageos_init_script_result gauge
+storageos_init_script_result{script="01-lio",path="01-lio/enable-lio.sh",result="passed"} 1
+storageos_init_script_result{script="01-lio",path="01-lio/enable-lio.sh",result="warning"} 0
+storageos_init_script_result{script="01-lio",path="01-lio/enable-lio.sh",result="failed"} 0
+storageos_init_script_result{script="01-lio",path="01-lio/enable-lio.sh",result="skipped"} 0
+storageos_init_script_result{script="01-lio",path="01-lio/enable-lio.sh",result="interrupted"} 0
+storageos_init_script_result{script="02-limits",path="02-limits/limits.sh",result="passed"} 0
+storageos_init_script_result{script="02-limits",path="02-limits/limits.sh",result="warning"} 0
+storageos_init_script_result{script="02-limits",path="02-limits/limits.sh",result="failed"} 1
+storageos_init_script_result{script="02-limits",path="02-limits/limits.sh",result="skipped"} 0
+storageos_init_script_result{script="02-limits",path="02-limits/limits.sh",result="interrupted"} 0
+# HELP storageos_init_script_metric Value measured by the init script, reported in its result file.
+# TYPE storageos_init_script_metric gauge
+storageos_init_script_metric{script="01-lio",path="01-lio/enable-lio.sh",name="load_seconds"} 0.25
+storageos_init_script_metric{script="01-lio",path="01-lio/enable-lio.sh",name="modules_loaded"} 5
+`
+
+	got := string(Format(testReport(), map[string]int64{"success": 5, "failure": 2}))
+	if got != want {
+		t.Errorf("unexpected metrics:\n\t(WNT) %s\n\t(GOT) %s", want, got)
+	}
+}
+
+func TestFormatScriptsOfADirectory(t *testing.T) {
+	r := &report.Report{
+		Scripts: []*report.Script{
+			{Name: "05-foo", RelPath: "05-foo/zzzscript100.sh", Status: report.StatusPassed, Metrics: map[string]float64{"count": 1}},
+			{Name: "05-foo", RelPath: "05-foo/zzzscript99.sh", Status: report.StatusPassed, Metrics: map[string]float64{"count": 2}},
+		},
+	}
+
+	seen := map[string]bool{}
+	for _, line := range strings.Split(string(Format(r, nil)), "\n") {
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		series := strings.Fields(line)[0]
+		if seen[series] {
+			t.Errorf("duplicate sample %s", series)
+		}
+		seen[series] = true
+	}
+}
+
+func TestWriteFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "init-metrics-test")
+	if err != nil {
+		t.Fatalf("failed to create directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "storageos-init.prom")
+
+	// Write the metrics of two failed runs. The runs counter must carry over.
+	for i := 0; i < 2; i++ {
+		if err := WriteFile(path, testReport()); err != nil {
+			t.Fatalf("failed to write metrics: %v", err)
+		}
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read metrics: %v", err)
+	}
+
+	for _, line := range []string{
+		`storageos_init_runs_total{result="failure"} 2`,
+		`storageos_init_runs_total{result="success"} 0`,
+	} {
+		if !strings.Contains(string(data), line+"\n") {
+			t.Errorf("metrics missing line %q:\n%s", line, data)
+		}
+	}
+
+	// No temporary files must be left behind.
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("failed to read directory: %v", err)
+	}
+	if len(files) != 1 {
+		t.Errorf("unexpected number of files:\n\t(WNT) %d\n\t(GOT) %d", 1, len(files))
+	}
+}
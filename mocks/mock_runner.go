@@ -6,6 +6,7 @@ package mocks
 
 import (
 	gomock "github.com/golang/mock/gomock"
+	script "github.com/storageos/init/script"
 	reflect "reflect"
 )
 
@@ -33,17 +34,16 @@ func (m *MockRunner) EXPECT() *MockRunnerMockRecorder {
 }
 
 // RunScript mocks base method
-func (m *MockRunner) RunScript(arg0 string, arg1 map[string]string, arg2 ...string) ([]byte, []byte, error) {
+func (m *MockRunner) RunScript(arg0 script.Script, arg1 map[string]string, arg2 ...string) (*script.Result, error) {
 	m.ctrl.T.Helper()
 	varargs := []interface{}{arg0, arg1}
 	for _, a := range arg2 {
 		varargs = append(varargs, a)
 	}
 	ret := m.ctrl.Call(m, "RunScript", varargs...)
-	ret0, _ := ret[0].([]byte)
-	ret1, _ := ret[1].([]byte)
-	ret2, _ := ret[2].(error)
-	return ret0, ret1, ret2
+	ret0, _ := ret[0].(*script.Result)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
 // RunScript indicates an expected call of RunScript
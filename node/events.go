@@ -0,0 +1,75 @@
+package node
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/storageos/init/report"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// EventComponent is the source component of the Node events.
+const EventComponent = "storageos-init"
+
+// eventStderrTail is the maximum number of bytes of the stderr of a failed
+// script added to the Node event message.
+const eventStderrTail = 256
+
+// Event returns the Node event for the results of a run. It has the reason
+// and message of the Node condition, followed by the tail of the stderr of the
+// failed scripts, and is a warning if a script failed.
+func Event(r *report.Report, nodeName string, now metav1.Time) *corev1.Event {
+	cond := Condition(r, now, nil)
+
+	eventType := corev1.EventTypeNormal
+	if cond.Status != corev1.ConditionTrue {
+		eventType = corev1.EventTypeWarning
+	}
+
+	message := cond.Message
+	for _, s := range r.Scripts {
+		if s.Status != report.StatusFailed && s.Status != report.StatusInterrupted {
+			continue
+		}
+		if stderr := strings.TrimSpace(s.Stderr); stderr != "" {
+			if len(stderr) > eventStderrTail {
+				stderr = "..." + stderr[len(stderr)-eventStderrTail:]
+			}
+			message += fmt.Sprintf("\n%s stderr: %s", s.Name, stderr)
+		}
+	}
+
+	return &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: nodeName + ".",
+			Namespace:    metav1.NamespaceDefault,
+		},
+		InvolvedObject: corev1.ObjectReference{
+			Kind: "Node",
+			Name: nodeName,
+		},
+		Reason:         cond.Reason,
+		Message:        message,
+		Type:           eventType,
+		Source:         corev1.EventSource{Component: EventComponent, Host: nodeName},
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+	}
+}
+
+// RecordEvent creates a Node event for the results of a run.
+func (p *Publisher) RecordEvent(r *report.Report) error {
+	n, err := p.client.CoreV1().Nodes().Get(p.nodeName, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	ev := Event(r, p.nodeName, metav1.Now())
+	// The Node events are listed by the Node UID, e.g. by kubectl describe.
+	ev.InvolvedObject.UID = n.UID
+	_, err = p.client.CoreV1().Events(ev.Namespace).Create(ev)
+	return err
+}
@@ -0,0 +1,75 @@
+package node
+
+import (
+	"testing"
+
+	"github.com/storageos/init/report"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestRecordEvent(t *testing.T) {
+	testcases := []struct {
+		name        string
+		scripts     []*report.Script
+		wantType    string
+		wantReason  string
+		wantMessage string
+	}{
+		{
+			name: "passed",
+			scripts: []*report.Script{
+				{Name: "01-lio", Status: report.StatusPassed},
+			},
+			wantType:    corev1.EventTypeNormal,
+			wantReason:  ReasonSucceeded,
+			wantMessage: "1 scripts passed",
+		},
+		{
+			name: "failed",
+			scripts: []*report.Script{
+				{Name: "01-lio", Status: report.StatusFailed, Error: "exited 1", Stderr: "ERROR: tcm_loop couldn't load\n"},
+			},
+			wantType:    corev1.EventTypeWarning,
+			wantReason:  ReasonFailed,
+			wantMessage: "failed scripts: 01-lio: exited 1\n01-lio stderr: ERROR: tcm_loop couldn't load",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			client := fake.NewSimpleClientset(&corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{Name: "node1", UID: "node1-uid"},
+			})
+
+			r := &report.Report{Scripts: tc.scripts}
+			if err := NewPublisher(client, "node1").RecordEvent(r); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			events, err := client.CoreV1().Events(metav1.NamespaceDefault).List(metav1.ListOptions{})
+			if err != nil {
+				t.Fatalf("failed to list events: %v", err)
+			}
+			if len(events.Items) != 1 {
+				t.Fatalf("unexpected number of events:\n\t(WNT) %d\n\t(GOT) %d", 1, len(events.Items))
+			}
+
+			ev := events.Items[0]
+			if ev.Type != tc.wantType {
+				t.Errorf("unexpected event type:\n\t(WNT) %s\n\t(GOT) %s", tc.wantType, ev.Type)
+			}
+			if ev.Reason != tc.wantReason {
+				t.Errorf("unexpected event reason:\n\t(WNT) %s\n\t(GOT) %s", tc.wantReason, ev.Reason)
+			}
+			if ev.Message != tc.wantMessage {
+				t.Errorf("unexpected event message:\n\t(WNT) %q\n\t(GOT) %q", tc.wantMessage, ev.Message)
+			}
+			if ev.InvolvedObject.Kind != "Node" || ev.InvolvedObject.Name != "node1" || ev.InvolvedObject.UID != "node1-uid" {
+				t.Errorf("unexpected involved object: %+v", ev.InvolvedObject)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package node
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/storageos/init/host"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// FeatureLabelPrefix is the prefix of the host feature Node labels. All the
+// Node labels with the prefix are managed by init.
+const FeatureLabelPrefix = "feature.storageos.com/"
+
+// FeatureLabels returns the Node labels for the host features.
+func FeatureLabels(f host.Features) map[string]string {
+	return map[string]string{
+		FeatureLabelPrefix + "target-core-user": strconv.FormatBool(f.TargetCoreUser),
+		FeatureLabelPrefix + "cgroup-v2":        strconv.FormatBool(f.CgroupV2),
+		FeatureLabelPrefix + "kernel-major":     strconv.Itoa(f.KernelMajor),
+		FeatureLabelPrefix + "kernel-minor":     strconv.Itoa(f.KernelMinor),
+		FeatureLabelPrefix + "hugepages":        strconv.FormatBool(f.HugePages),
+	}
+}
+
+// featureLabelsPatch returns the label changes to apply on the existing Node
+// labels to publish the feature labels. The stale feature labels, with the
+// feature prefix but not in the feature labels, are removed with a nil value.
+// It returns an empty map if no change is needed.
+func featureLabelsPatch(existing, features map[string]string) map[string]*string {
+	patch := map[string]*string{}
+
+	for k, v := range features {
+		if cur, ok := existing[k]; ok && cur == v {
+			continue
+		}
+		v := v
+		patch[k] = &v
+	}
+
+	for k := range existing {
+		if _, ok := features[k]; !ok && strings.HasPrefix(k, FeatureLabelPrefix) {
+			patch[k] = nil
+		}
+	}
+
+	return patch
+}
+
+// PublishFeatures sets the feature labels of the host features on the Node
+// and removes the stale feature labels.
+func (p *Publisher) PublishFeatures(f host.Features) error {
+	n, err := p.client.CoreV1().Nodes().Get(p.nodeName, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	labels := featureLabelsPatch(n.Labels, FeatureLabels(f))
+	if len(labels) == 0 {
+		return nil
+	}
+
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"labels": labels,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := p.client.CoreV1().Nodes().Patch(p.nodeName, types.MergePatchType, patch); err != nil {
+		return fmt.Errorf("failed to update node feature labels: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,99 @@
+package node
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/storageos/init/host"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestFeatureLabelsPatch(t *testing.T) {
+	str := func(s string) *string { return &s }
+
+	testcases := []struct {
+		name      string
+		existing  map[string]string
+		features  map[string]string
+		wantPatch map[string]*string
+	}{
+		{
+			name:     "new labels",
+			existing: map[string]string{"foo": "bar"},
+			features: map[string]string{
+				FeatureLabelPrefix + "cgroup-v2": "true",
+			},
+			wantPatch: map[string]*string{
+				FeatureLabelPrefix + "cgroup-v2": str("true"),
+			},
+		},
+		{
+			name: "unchanged labels",
+			existing: map[string]string{
+				FeatureLabelPrefix + "cgroup-v2": "true",
+			},
+			features: map[string]string{
+				FeatureLabelPrefix + "cgroup-v2": "true",
+			},
+			wantPatch: map[string]*string{},
+		},
+		{
+			name: "changed and stale labels",
+			existing: map[string]string{
+				"foo":                              "bar",
+				FeatureLabelPrefix + "cgroup-v2":   "false",
+				FeatureLabelPrefix + "old-feature": "true",
+			},
+			features: map[string]string{
+				FeatureLabelPrefix + "cgroup-v2": "true",
+			},
+			wantPatch: map[string]*string{
+				FeatureLabelPrefix + "cgroup-v2":   str("true"),
+				FeatureLabelPrefix + "old-feature": nil,
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			patch := featureLabelsPatch(tc.existing, tc.features)
+			if !reflect.DeepEqual(patch, tc.wantPatch) {
+				t.Errorf("unexpected patch:\n\t(WNT) %v\n\t(GOT) %v", tc.wantPatch, patch)
+			}
+		})
+	}
+}
+
+func TestPublishFeatures(t *testing.T) {
+	client := fake.NewSimpleClientset(&corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "node1",
+			Labels: map[string]string{"foo": "bar"},
+		},
+	})
+
+	f := host.Features{TargetCoreUser: true, KernelMajor: 5, KernelMinor: 4}
+	if err := NewPublisher(client, "node1").PublishFeatures(f); err != nil {
+		t.Fatalf("failed to publish features: %v", err)
+	}
+
+	n, err := client.CoreV1().Nodes().Get("node1", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get node: %v", err)
+	}
+
+	want := map[string]string{
+		"foo":                                    "bar",
+		"feature.storageos.com/target-core-user": "true",
+		"feature.storageos.com/cgroup-v2":        "false",
+		"feature.storageos.com/kernel-major":     "5",
+		"feature.storageos.com/kernel-minor":     "4",
+		"feature.storageos.com/hugepages":        "false",
+	}
+	if !reflect.DeepEqual(n.Labels, want) {
+		t.Errorf("unexpected labels:\n\t(WNT) %v\n\t(GOT) %v", want, n.Labels)
+	}
+}
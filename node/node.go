@@ -0,0 +1,223 @@
+// Package node publishes the results of an init run on the k8s Node the init
+// runs on, as a Node condition and Node labels.
+package node
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/storageos/init/report"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// NameEnvVar is the env var that contains the name of the Node, set with
+	// the downward API.
+	NameEnvVar = "NODE_NAME"
+
+	// ConditionType is the type of the Node condition that reflects the init
+	// readiness of the Node.
+	ConditionType corev1.NodeConditionType = "StorageOSInitReady"
+	// ReasonSucceeded is the condition reason when all the scripts passed.
+	ReasonSucceeded = "InitSucceeded"
+	// ReasonFailed is the condition reason when a script failed.
+	ReasonFailed = "InitFailed"
+
+	// LabelPrefix is the prefix of the script result Node labels.
+	LabelPrefix = "storageos.com/"
+	// LabelReady is the label value of a script that passed.
+	LabelReady = "ready"
+	// LabelFailed is the label value of a script that failed.
+	LabelFailed = "failed"
+)
+
+// orderPrefix matches the execution order prefix of a script name, e.g. "01-".
+var orderPrefix = regexp.MustCompile(`^[0-9]+[-_]`)
+
+// Publisher publishes the init results on a k8s Node.
+type Publisher struct {
+	client   kubernetes.Interface
+	nodeName string
+	labels   bool
+}
+
+// NewPublisher returns an initialized Publisher for a given Node.
+func NewPublisher(client kubernetes.Interface, nodeName string) *Publisher {
+	return &Publisher{
+		client:   client,
+		nodeName: nodeName,
+	}
+}
+
+// SetLabels sets if the script result labels should be published.
+func (p *Publisher) SetLabels(enabled bool) *Publisher {
+	p.labels = enabled
+	return p
+}
+
+// Publish updates the Node condition, and the Node labels if enabled, with the
+// results of a run. The script result labels of the scripts that are skipped
+// or no longer run are removed. The scripts without a valid label key are not
+// labelled and returned in the error, after the other labels are published.
+func (p *Publisher) Publish(r *report.Report) error {
+	n, err := p.client.CoreV1().Nodes().Get(p.nodeName, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	cond := Condition(r, metav1.Now(), n.Status.Conditions)
+	patch, err := json.Marshal(map[string]interface{}{
+		"status": map[string]interface{}{
+			"conditions": []corev1.NodeCondition{cond},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := p.client.CoreV1().Nodes().PatchStatus(p.nodeName, patch); err != nil {
+		return fmt.Errorf("failed to update node condition: %v", err)
+	}
+
+	if !p.labels {
+		return nil
+	}
+
+	labels, labelsErr := Labels(r)
+	if changes := labelsPatch(n.Labels, labels); len(changes) > 0 {
+		patch, err = json.Marshal(map[string]interface{}{
+			"metadata": map[string]interface{}{
+				"labels": changes,
+			},
+		})
+		if err != nil {
+			return err
+		}
+		if _, err := p.client.CoreV1().Nodes().Patch(p.nodeName, types.MergePatchType, patch); err != nil {
+			return fmt.Errorf("failed to update node labels: %v", err)
+		}
+	}
+
+	return labelsErr
+}
+
+// Condition returns the Node condition for the results of a run. The last
+// transition time of the existing condition is kept if the status is
+// unchanged.
+func Condition(r *report.Report, now metav1.Time, existing []corev1.NodeCondition) corev1.NodeCondition {
+	cond := corev1.NodeCondition{
+		Type:               ConditionType,
+		Status:             corev1.ConditionTrue,
+		Reason:             ReasonSucceeded,
+		LastHeartbeatTime:  now,
+		LastTransitionTime: now,
+	}
+
+	var failed, warned, skipped []string
+	passed := 0
+	for _, s := range r.Scripts {
+		switch s.Status {
+		case report.StatusFailed, report.StatusInterrupted:
+			failed = append(failed, fmt.Sprintf("%s: %s", s.Name, s.Describe()))
+		case report.StatusWarning:
+			warned = append(warned, s.Name)
+			passed++
+		case report.StatusSkipped:
+			skipped = append(skipped, s.Name)
+		default:
+			passed++
+		}
+	}
+
+	if len(failed) > 0 {
+		cond.Status = corev1.ConditionFalse
+		cond.Reason = ReasonFailed
+		cond.Message = fmt.Sprintf("failed scripts: %s", strings.Join(failed, ", "))
+		if len(warned) > 0 {
+			cond.Message += fmt.Sprintf("; warnings: %s", strings.Join(warned, ", "))
+		}
+	} else {
+		cond.Message = fmt.Sprintf("%d scripts passed", passed)
+		if len(warned) > 0 {
+			cond.Message += fmt.Sprintf(", with warnings: %s", strings.Join(warned, ", "))
+		}
+	}
+	if len(skipped) > 0 {
+		cond.Message += fmt.Sprintf("; skipped scripts: %s", strings.Join(skipped, ", "))
+	}
+
+	for _, c := range existing {
+		if c.Type == ConditionType && c.Status == cond.Status {
+			cond.LastTransitionTime = c.LastTransitionTime
+		}
+	}
+
+	return cond
+}
+
+// Labels returns the script result Node labels for the results of a run. The
+// skipped scripts are not labelled. The scripts whose label key is not a valid
+// label name are not labelled either, and returned in the error.
+func Labels(r *report.Report) (map[string]string, error) {
+	labels := map[string]string{}
+	var invalid []string
+	for _, s := range r.Scripts {
+		if s.Status == report.StatusSkipped {
+			continue
+		}
+		key := LabelKey(s.Name)
+		if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+			invalid = append(invalid, fmt.Sprintf("%s: invalid label key %q: %s", s.Name, key, strings.Join(errs, ", ")))
+			continue
+		}
+		value := LabelReady
+		if s.Status == report.StatusFailed || s.Status == report.StatusInterrupted {
+			value = LabelFailed
+		}
+		labels[key] = value
+	}
+	if len(invalid) > 0 {
+		return labels, fmt.Errorf("scripts not labelled: %s", strings.Join(invalid, "; "))
+	}
+	return labels, nil
+}
+
+// labelsPatch returns the label changes to apply on the existing Node labels
+// to publish the script result labels. The stale script result labels, with
+// the label prefix and a result value but not in the labels, e.g. of a skipped
+// script, are removed with a nil value. It returns an empty map if no change
+// is needed.
+func labelsPatch(existing, labels map[string]string) map[string]*string {
+	patch := map[string]*string{}
+
+	for k, v := range labels {
+		if cur, ok := existing[k]; ok && cur == v {
+			continue
+		}
+		v := v
+		patch[k] = &v
+	}
+
+	for k, v := range existing {
+		if _, ok := labels[k]; ok || !strings.HasPrefix(k, LabelPrefix) {
+			continue
+		}
+		if v == LabelReady || v == LabelFailed {
+			patch[k] = nil
+		}
+	}
+
+	return patch
+}
+
+// LabelKey returns the Node label key of a script, the script name without the
+// execution order prefix, e.g. storageos.com/lio for 01-lio.
+func LabelKey(scriptName string) string {
+	return LabelPrefix + orderPrefix.ReplaceAllString(scriptName, "")
+}
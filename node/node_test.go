@@ -0,0 +1,264 @@
+package node
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/storageos/init/report"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestPublish(t *testing.T) {
+	testcases := []struct {
+		name       string
+		existing   map[string]string
+		scripts    []*report.Script
+		labels     bool
+		wantStatus corev1.ConditionStatus
+		wantReason string
+		wantLabels map[string]string
+		// wantRemoved are the labels removed by the labels patch.
+		wantRemoved []string
+		wantErr     bool
+	}{
+		{
+			name: "passed",
+			scripts: []*report.Script{
+				{Name: "01-lio", Status: report.StatusPassed},
+				{Name: "02-limits", Status: report.StatusWarning},
+			},
+			wantStatus: corev1.ConditionTrue,
+			wantReason: ReasonSucceeded,
+			wantLabels: map[string]string{"foo": "bar"},
+		},
+		{
+			name: "failed with labels",
+			scripts: []*report.Script{
+				{Name: "01-lio", Status: report.StatusPassed},
+				{Name: "02-limits", Status: report.StatusFailed, Error: "exited 1"},
+			},
+			labels:     true,
+			wantStatus: corev1.ConditionFalse,
+			wantReason: ReasonFailed,
+			wantLabels: map[string]string{
+				"foo":                  "bar",
+				"storageos.com/lio":    LabelReady,
+				"storageos.com/limits": LabelFailed,
+			},
+		},
+		{
+			name: "skipped after ready",
+			existing: map[string]string{
+				"foo":                   "bar",
+				"storageos.com/lio":     LabelReady,
+				"storageos.com/limits":  LabelFailed,
+				"storageos.com/removed": LabelReady,
+				"storageos.com/other":   "custom",
+			},
+			scripts: []*report.Script{
+				{Name: "01-lio", Status: report.StatusSkipped},
+				{Name: "02-limits", Status: report.StatusPassed},
+			},
+			labels:     true,
+			wantStatus: corev1.ConditionTrue,
+			wantReason: ReasonSucceeded,
+			wantLabels: map[string]string{
+				"foo":                  "bar",
+				"storageos.com/limits": LabelReady,
+				"storageos.com/other":  "custom",
+			},
+			wantRemoved: []string{"storageos.com/lio", "storageos.com/removed"},
+		},
+		{
+			name: "invalid label key",
+			scripts: []*report.Script{
+				{Name: "01-lio", Status: report.StatusPassed},
+				{Name: "02-bad name!", Status: report.StatusPassed},
+			},
+			labels:     true,
+			wantStatus: corev1.ConditionTrue,
+			wantReason: ReasonSucceeded,
+			wantLabels: map[string]string{
+				"foo":               "bar",
+				"storageos.com/lio": LabelReady,
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			existing := tc.existing
+			if existing == nil {
+				existing = map[string]string{"foo": "bar"}
+			}
+			client := fake.NewSimpleClientset(&corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:   "node1",
+					Labels: existing,
+				},
+			})
+
+			r := &report.Report{Scripts: tc.scripts}
+			if err := NewPublisher(client, "node1").SetLabels(tc.labels).Publish(r); (err != nil) != tc.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			n, err := client.CoreV1().Nodes().Get("node1", metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("failed to get node: %v", err)
+			}
+
+			var cond *corev1.NodeCondition
+			for i, c := range n.Status.Conditions {
+				if c.Type == ConditionType {
+					cond = &n.Status.Conditions[i]
+				}
+			}
+			if cond == nil {
+				t.Fatalf("node condition %s not found", ConditionType)
+			}
+			if cond.Status != tc.wantStatus {
+				t.Errorf("unexpected condition status:\n\t(WNT) %s\n\t(GOT) %s", tc.wantStatus, cond.Status)
+			}
+			if cond.Reason != tc.wantReason {
+				t.Errorf("unexpected condition reason:\n\t(WNT) %s\n\t(GOT) %s", tc.wantReason, cond.Reason)
+			}
+
+			// The fake client doesn't remove the labels set to null in a
+			// merge patch, check the patch instead.
+			var removed []string
+			for _, action := range client.Actions() {
+				patch, ok := action.(k8stesting.PatchAction)
+				if !ok || patch.GetSubresource() != "" {
+					continue
+				}
+				var p struct {
+					Metadata struct {
+						Labels map[string]*string `json:"labels"`
+					} `json:"metadata"`
+				}
+				if err := json.Unmarshal(patch.GetPatch(), &p); err != nil {
+					t.Fatalf("invalid patch: %v", err)
+				}
+				for k, v := range p.Metadata.Labels {
+					if v == nil {
+						removed = append(removed, k)
+						delete(n.Labels, k)
+					}
+				}
+			}
+			sort.Strings(removed)
+			if len(removed) > 0 || len(tc.wantRemoved) > 0 {
+				if !reflect.DeepEqual(removed, tc.wantRemoved) {
+					t.Errorf("unexpected removed labels:\n\t(WNT) %v\n\t(GOT) %v", tc.wantRemoved, removed)
+				}
+			}
+
+			if !reflect.DeepEqual(n.Labels, tc.wantLabels) {
+				t.Errorf("unexpected labels:\n\t(WNT) %v\n\t(GOT) %v", tc.wantLabels, n.Labels)
+			}
+		})
+	}
+}
+
+func TestCondition(t *testing.T) {
+	earlier := metav1.NewTime(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	now := metav1.NewTime(time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC))
+
+	passed := &report.Report{Scripts: []*report.Script{
+		{Name: "01-lio", Status: report.StatusPassed},
+		{Name: "02-limits", Status: report.StatusWarning},
+		{Name: "03-foo", Status: report.StatusSkipped},
+	}}
+	failed := &report.Report{Scripts: []*report.Script{
+		{Name: "01-lio", Status: report.StatusFailed, Error: "exited 1"},
+	}}
+
+	testcases := []struct {
+		name               string
+		report             *report.Report
+		existing           []corev1.NodeCondition
+		wantMessage        string
+		wantTransitionTime metav1.Time
+	}{
+		{
+			name:               "new condition",
+			report:             passed,
+			wantMessage:        "2 scripts passed, with warnings: 02-limits; skipped scripts: 03-foo",
+			wantTransitionTime: now,
+		},
+		{
+			name:   "unchanged status",
+			report: passed,
+			existing: []corev1.NodeCondition{
+				{Type: ConditionType, Status: corev1.ConditionTrue, LastTransitionTime: earlier},
+			},
+			wantMessage:        "2 scripts passed, with warnings: 02-limits; skipped scripts: 03-foo",
+			wantTransitionTime: earlier,
+		},
+		{
+			name:   "changed status",
+			report: failed,
+			existing: []corev1.NodeCondition{
+				{Type: ConditionType, Status: corev1.ConditionTrue, LastTransitionTime: earlier},
+			},
+			wantMessage:        "failed scripts: 01-lio: exited 1",
+			wantTransitionTime: now,
+		},
+		{
+			name: "remediation",
+			report: &report.Report{Scripts: []*report.Script{
+				{Name: "01-lio", Status: report.StatusFailed, Error: "tcm_loop missing", Remediation: "install linux-modules-extra"},
+			}},
+			wantMessage:        "failed scripts: 01-lio: tcm_loop missing (remediation: install linux-modules-extra)",
+			wantTransitionTime: now,
+		},
+		{
+			name: "failures and warnings",
+			report: &report.Report{Scripts: []*report.Script{
+				{Name: "01-lio", Status: report.StatusFailed, Error: "exited 1"},
+				{Name: "02-limits", Status: report.StatusFailed, Error: "max pids too low"},
+				{Name: "03-foo", Status: report.StatusWarning},
+			}},
+			wantMessage:        "failed scripts: 01-lio: exited 1, 02-limits: max pids too low; warnings: 03-foo",
+			wantTransitionTime: now,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			cond := Condition(tc.report, now, tc.existing)
+			if cond.Message != tc.wantMessage {
+				t.Errorf("unexpected message:\n\t(WNT) %s\n\t(GOT) %s", tc.wantMessage, cond.Message)
+			}
+			if !cond.LastTransitionTime.Equal(&tc.wantTransitionTime) {
+				t.Errorf("unexpected transition time:\n\t(WNT) %s\n\t(GOT) %s", tc.wantTransitionTime, cond.LastTransitionTime)
+			}
+			if !cond.LastHeartbeatTime.Equal(&now) {
+				t.Errorf("unexpected heartbeat time:\n\t(WNT) %s\n\t(GOT) %s", now, cond.LastHeartbeatTime)
+			}
+		})
+	}
+}
+
+func TestLabelKey(t *testing.T) {
+	testcases := map[string]string{
+		"01-lio":    "storageos.com/lio",
+		"02_limits": "storageos.com/limits",
+		"foo":       "storageos.com/foo",
+	}
+
+	for name, want := range testcases {
+		if got := LabelKey(name); got != want {
+			t.Errorf("unexpected label key for %s:\n\t(WNT) %s\n\t(GOT) %s", name, want, got)
+		}
+	}
+}
@@ -0,0 +1,325 @@
+// Package report records the results of an init run.
+package report
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/storageos/init/fileutil"
+	"github.com/storageos/init/script"
+)
+
+// Status is the status of a script execution.
+type Status string
+
+const (
+	// StatusPassed is the status of a script that exited successfully.
+	StatusPassed Status = "passed"
+	// StatusWarning is the status of a script that exited successfully but
+	// wrote to stderr.
+	StatusWarning Status = "warning"
+	// StatusFailed is the status of a script that failed to run or exited
+	// with an error.
+	StatusFailed Status = "failed"
+	// StatusSkipped is the status of a script that was not executed.
+	StatusSkipped Status = "skipped"
+	// StatusInterrupted is the status of a script that was terminated, or
+	// not started, because init received a termination signal.
+	StatusInterrupted Status = "interrupted"
+)
+
+// Statuses is the list of all the script statuses.
+var Statuses = []Status{StatusPassed, StatusWarning, StatusFailed, StatusSkipped, StatusInterrupted}
+
+// Script is the report of a script execution.
+type Script struct {
+	// Name is the name of the script.
+	Name string `json:"name"`
+	// Path is the path of the script file.
+	Path string `json:"path"`
+	// RelPath is the path of the script file relative to its scripts
+	// directory, unique in a run.
+	RelPath string `json:"relPath"`
+	// Source describes where the script comes from.
+	Source string `json:"source"`
+	// Status is the status of the execution.
+	Status Status `json:"status"`
+	// Start is the time the execution started.
+	Start time.Time `json:"start"`
+	// Duration is the duration of the execution.
+	Duration time.Duration `json:"duration"`
+	// Error is the execution error, if any.
+	Error string `json:"error,omitempty"`
+	// Message is a human readable detail of the status, e.g. the reason the
+	// script was skipped.
+	Message string `json:"message,omitempty"`
+	// Remediation is a hint to fix a failure or warning, reported by the
+	// script.
+	Remediation string `json:"remediation,omitempty"`
+	// Facts are the facts discovered by the script.
+	Facts map[string]string `json:"facts,omitempty"`
+	// Metrics are the numeric values measured by the script.
+	Metrics map[string]float64 `json:"metrics,omitempty"`
+	// Outputs are the named values published by the script to the scripts
+	// that run after it.
+	Outputs map[string]string `json:"outputs,omitempty"`
+	// Stdout and Stderr are the output of the script retained by the runner,
+	// the head and the tail of each stream with a truncation marker in
+	// between if it was truncated.
+	Stdout string `json:"stdout,omitempty"`
+	Stderr string `json:"stderr,omitempty"`
+	// StdoutTruncated and StderrTruncated are the number of output bytes
+	// discarded from Stdout and Stderr.
+	StdoutTruncated int64 `json:"stdoutTruncated,omitempty"`
+	StderrTruncated int64 `json:"stderrTruncated,omitempty"`
+	// Artifacts are the paths of the files preserved by the script.
+	Artifacts []string `json:"artifacts,omitempty"`
+	// Result is the result of the execution. It's nil if the script could
+	// not be started.
+	Result *script.Result `json:"-"`
+}
+
+// Report is the report of an init run.
+type Report struct {
+	// NodeImage is the StorageOS node container image.
+	NodeImage string `json:"nodeImage"`
+	// Start and End are the start and end times of the run.
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	// Scripts are the reports of the executed scripts, in execution order.
+	Scripts []*Script `json:"scripts"`
+	// Summary is the consolidated summary of the finished run, see
+	// Summarize.
+	Summary []string `json:"summary,omitempty"`
+}
+
+// New returns a Report for a run with a given node image, starting now.
+func New(nodeImage string) *Report {
+	return &Report{
+		NodeImage: nodeImage,
+		Start:     time.Now(),
+		Scripts:   []*Script{},
+	}
+}
+
+// AddScript records the execution of a script. The status is derived from the
+// execution result and error, then from the structured result record of a
+// script that exited successfully, if any.
+func (r *Report) AddScript(s script.Script, start time.Time, result *script.Result, err error) *Script {
+	sr := &Script{
+		Name:     s.Name,
+		Path:     s.Path,
+		RelPath:  s.RelPath,
+		Source:   s.Source,
+		Status:   StatusPassed,
+		Start:    start,
+		Duration: time.Since(start),
+		Result:   result,
+	}
+
+	switch {
+	case errors.Is(err, script.ErrInterrupted) || (result != nil && result.Interrupted):
+		sr.Status = StatusInterrupted
+		sr.Error = script.ErrInterrupted.Error()
+		if result != nil {
+			sr.Error = result.String()
+		}
+	case err != nil:
+		sr.Status = StatusFailed
+		sr.Error = err.Error()
+		if result != nil {
+			sr.Error = result.String()
+		}
+	case result != nil && len(result.Stderr) > 0:
+		sr.Status = StatusWarning
+	}
+
+	if result != nil && result.Record != nil {
+		sr.applyRecord(result.Record)
+	}
+	if result != nil {
+		sr.Stdout = string(result.Stdout)
+		sr.Stderr = string(result.Stderr)
+		sr.StdoutTruncated = result.StdoutTruncated
+		sr.StderrTruncated = result.StderrTruncated
+		sr.Artifacts = result.Artifacts
+	}
+
+	r.Scripts = append(r.Scripts, sr)
+	return sr
+}
+
+// applyRecord merges the structured result record of a script. The record
+// status only applies to a script that exited successfully.
+func (sr *Script) applyRecord(rec *script.Record) {
+	sr.Message = rec.Message
+	sr.Remediation = rec.Remediation
+	sr.Facts = rec.Facts
+	sr.Metrics = rec.Metrics
+	sr.Outputs = rec.Outputs
+
+	if sr.Status != StatusPassed && sr.Status != StatusWarning {
+		return
+	}
+	switch rec.Status {
+	case script.RecordPassed:
+		sr.Status = StatusPassed
+	case script.RecordWarning:
+		sr.Status = StatusWarning
+	case script.RecordSkipped:
+		sr.Status = StatusSkipped
+	case script.RecordFailed:
+		sr.Status = StatusFailed
+		sr.Error = "reported failed"
+		if rec.Message != "" {
+			sr.Error = rec.Message
+		}
+	}
+}
+
+// SkipScript records a script that was not executed, with the reason.
+func (r *Report) SkipScript(s script.Script, reason string) *Script {
+	sr := &Script{
+		Name:    s.Name,
+		Path:    s.Path,
+		RelPath: s.RelPath,
+		Source:  s.Source,
+		Status:  StatusSkipped,
+		Start:   time.Now(),
+		Message: reason,
+	}
+	r.Scripts = append(r.Scripts, sr)
+	return sr
+}
+
+// OutputEnvVars returns the env vars of the outputs published by the scripts
+// that didn't fail, namespaced by script name.
+func (r *Report) OutputEnvVars() map[string]string {
+	env := map[string]string{}
+	for _, s := range r.Scripts {
+		if s.Status == StatusFailed || s.Status == StatusInterrupted {
+			continue
+		}
+		for name, value := range s.Outputs {
+			env[script.OutputEnvVar(s.Name, name)] = value
+		}
+	}
+	return env
+}
+
+// Describe returns a human readable detail of the script status: the error
+// of a failed script, the message of a warning, followed by the remediation
+// hint if any.
+func (s *Script) Describe() string {
+	desc := s.Message
+	switch {
+	case s.Status == StatusFailed || s.Status == StatusInterrupted:
+		desc = s.Error
+	case s.Status == StatusWarning && desc == "":
+		desc = "wrote to stderr"
+	}
+	if s.Remediation != "" {
+		desc += fmt.Sprintf(" (remediation: %s)", s.Remediation)
+	}
+	return desc
+}
+
+// Summarize returns a consolidated summary of the run: the number of scripts
+// by status, followed by a line for each failed, interrupted and warning
+// script, in execution order.
+func (r *Report) Summarize() []string {
+	counts := map[Status]int{}
+	details := []string{}
+	for _, s := range r.Scripts {
+		counts[s.Status]++
+		switch s.Status {
+		case StatusFailed, StatusInterrupted, StatusWarning:
+			details = append(details, fmt.Sprintf("%s: %s: %s", s.Status, s.Name, s.Describe()))
+		}
+	}
+
+	total := fmt.Sprintf("%d scripts", len(r.Scripts))
+	for _, status := range Statuses {
+		if counts[status] > 0 {
+			total += fmt.Sprintf(", %d %s", counts[status], status)
+		}
+	}
+	return append([]string{total}, details...)
+}
+
+// Finish marks the end of the run and records its summary.
+func (r *Report) Finish() {
+	r.End = time.Now()
+	r.Summary = r.Summarize()
+}
+
+// Success returns true if none of the scripts failed or was interrupted.
+func (r *Report) Success() bool {
+	for _, s := range r.Scripts {
+		if s.Status == StatusFailed || s.Status == StatusInterrupted {
+			return false
+		}
+	}
+	return true
+}
+
+// WriteFile writes the report as indented JSON to a file. The file is written
+// atomically, so that a reader never reads a partial report.
+func (r *Report) WriteFile(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return fileutil.WriteFileAtomic(path, append(data, '\n'), 0644)
+}
+
+// ReadFile reads a report written by WriteFile.
+func ReadFile(path string) (*Report, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Report{}
+	if err := json.Unmarshal(data, r); err != nil {
+		return nil, fmt.Errorf("invalid report %s: %v", path, err)
+	}
+	return r, nil
+}
+
+// Merge returns a copy of the report updated with a later run of some of its
+// scripts, e.g. a recheck: each script of the later run replaces the script
+// with the same relative path, in place, or is appended if the report doesn't
+// have it. The node image and the run times are the later run's, and the
+// summary is recomputed if the later run is finished.
+func (r *Report) Merge(later *Report) *Report {
+	merged := &Report{
+		NodeImage: later.NodeImage,
+		Start:     later.Start,
+		End:       later.End,
+		Scripts:   make([]*Script, 0, len(r.Scripts)+len(later.Scripts)),
+	}
+
+	index := map[string]int{}
+	for _, s := range r.Scripts {
+		index[s.RelPath] = len(merged.Scripts)
+		merged.Scripts = append(merged.Scripts, s)
+	}
+	for _, s := range later.Scripts {
+		if i, ok := index[s.RelPath]; ok {
+			merged.Scripts[i] = s
+			continue
+		}
+		index[s.RelPath] = len(merged.Scripts)
+		merged.Scripts = append(merged.Scripts, s)
+	}
+
+	if !later.End.IsZero() {
+		merged.Summary = merged.Summarize()
+	}
+	return merged
+}
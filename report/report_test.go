@@ -0,0 +1,261 @@
+package report
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/storageos/init/script"
+)
+
+func TestAddScript(t *testing.T) {
+	testcases := []struct {
+		name        string
+		result      *script.Result
+		err         error
+		wantStatus  Status
+		wantError   string
+		wantSuccess bool
+	}{
+		{
+			name:        "passed",
+			result:      &script.Result{},
+			wantStatus:  StatusPassed,
+			wantSuccess: true,
+		},
+		{
+			name:        "warning",
+			result:      &script.Result{Stderr: []byte("low limit")},
+			wantStatus:  StatusWarning,
+			wantSuccess: true,
+		},
+		{
+			name:       "failed",
+			result:     &script.Result{ExitCode: 3},
+			err:        errors.New("exit status 3"),
+			wantStatus: StatusFailed,
+			wantError:  "exited 3",
+		},
+		{
+			name:       "not started",
+			err:        errors.New("permission denied"),
+			wantStatus: StatusFailed,
+			wantError:  "permission denied",
+		},
+		{
+			name:       "interrupted",
+			result:     &script.Result{ExitCode: -1, Signal: syscall.SIGTERM, Interrupted: true},
+			err:        errors.New("signal: terminated"),
+			wantStatus: StatusInterrupted,
+			wantError:  "interrupted, killed by SIGTERM",
+		},
+		{
+			name:        "record overrides warning",
+			result:      &script.Result{Stderr: []byte("noise"), Record: &script.Record{Status: script.RecordPassed, Message: "all good"}},
+			wantStatus:  StatusPassed,
+			wantSuccess: true,
+		},
+		{
+			name:       "record failure",
+			result:     &script.Result{Record: &script.Record{Status: script.RecordFailed, Message: "tcm_loop missing"}},
+			wantStatus: StatusFailed,
+			wantError:  "tcm_loop missing",
+		},
+		{
+			name:        "record skip",
+			result:      &script.Result{Record: &script.Record{Status: script.RecordSkipped}},
+			wantStatus:  StatusSkipped,
+			wantSuccess: true,
+		},
+		{
+			name:       "exit code over record",
+			result:     &script.Result{ExitCode: 1, Record: &script.Record{Status: script.RecordPassed}},
+			err:        errors.New("exit status 1"),
+			wantStatus: StatusFailed,
+			wantError:  "exited 1",
+		},
+		{
+			name:       "interrupted before start",
+			err:        script.ErrInterrupted,
+			wantStatus: StatusInterrupted,
+			wantError:  "interrupted before start",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := New("storageos/node:test")
+			s := r.AddScript(script.Script{Name: "foo", Path: "/scripts/foo.sh"}, time.Now(), tc.result, tc.err)
+
+			if s.Status != tc.wantStatus {
+				t.Errorf("unexpected status:\n\t(WNT) %s\n\t(GOT) %s", tc.wantStatus, s.Status)
+			}
+			if s.Error != tc.wantError {
+				t.Errorf("unexpected error:\n\t(WNT) %s\n\t(GOT) %s", tc.wantError, s.Error)
+			}
+			if r.Success() != tc.wantSuccess {
+				t.Errorf("unexpected report success:\n\t(WNT) %t\n\t(GOT) %t", tc.wantSuccess, r.Success())
+			}
+		})
+	}
+}
+
+func TestOutputEnvVars(t *testing.T) {
+	r := New("storageos/node:test")
+	r.AddScript(script.Script{Name: "01-lio"}, time.Now(), &script.Result{
+		Record: &script.Record{Outputs: map[string]string{"LIO_USER_BACKSTORE": "available"}},
+	}, nil)
+	r.SkipScript(script.Script{Name: "02-limits"}, "skipped by config file")
+	r.AddScript(script.Script{Name: "03-bar"}, time.Now(), &script.Result{
+		Record: &script.Record{Status: script.RecordFailed, Outputs: map[string]string{"BAR": "baz"}},
+	}, nil)
+
+	want := map[string]string{"INIT_OUTPUT_01_LIO_LIO_USER_BACKSTORE": "available"}
+	if got := r.OutputEnvVars(); !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected output env vars:\n\t(WNT) %v\n\t(GOT) %v", want, got)
+	}
+}
+
+func TestSummary(t *testing.T) {
+	r := New("storageos/node:test")
+	r.AddScript(script.Script{Name: "01-lio"}, time.Now(), &script.Result{
+		ExitCode: 1,
+		Record:   &script.Record{Remediation: "modprobe tcm_loop"},
+	}, errors.New("exit status 1"))
+	r.AddScript(script.Script{Name: "02-limits"}, time.Now(), &script.Result{
+		Record: &script.Record{Status: script.RecordWarning, Message: "low max pids"},
+	}, nil)
+	r.AddScript(script.Script{Name: "03-foo"}, time.Now(), &script.Result{Stderr: []byte("noise")}, nil)
+	r.AddScript(script.Script{Name: "04-bar"}, time.Now(), &script.Result{}, nil)
+	r.SkipScript(script.Script{Name: "05-baz"}, "not selected by -only")
+
+	want := []string{
+		"5 scripts, 1 passed, 2 warning, 1 failed, 1 skipped",
+		"failed: 01-lio: exited 1 (remediation: modprobe tcm_loop)",
+		"warning: 02-limits: low max pids",
+		"warning: 03-foo: wrote to stderr",
+	}
+	if got := r.Summarize(); !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected summary:\n\t(WNT) %q\n\t(GOT) %q", want, got)
+	}
+
+	// The summary is recorded when the run finishes.
+	r.Finish()
+	if !reflect.DeepEqual(r.Summary, want) {
+		t.Errorf("unexpected report summary:\n\t(WNT) %q\n\t(GOT) %q", want, r.Summary)
+	}
+}
+
+func TestWriteFile(t *testing.T) {
+	r := New("storageos/node:test")
+	r.AddScript(script.Script{Name: "01-lio", Path: "/scripts/01-lio/enable-lio.sh"}, time.Now(), &script.Result{
+		Stdout:          []byte("INFO: Checking configfs\n"),
+		Stderr:          []byte("head\n[... 4096 bytes truncated ...]\ntail\n"),
+		StderrTruncated: 4096,
+		Artifacts:       []string{"/var/lib/storageos/init/artifacts/01-lio/lsmod.txt"},
+	}, nil)
+	r.Finish()
+
+	path := filepath.Join(t.TempDir(), "report.json")
+	if err := r.WriteFile(path); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read report: %v", err)
+	}
+	var got Report
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("invalid report: %v", err)
+	}
+	if got.NodeImage != r.NodeImage || len(got.Scripts) != 1 {
+		t.Fatalf("unexpected report: %s", data)
+	}
+	if got.Scripts[0].Stdout != "INFO: Checking configfs\n" || got.Scripts[0].Stderr != "head\n[... 4096 bytes truncated ...]\ntail\n" {
+		t.Errorf("unexpected output: %q, %q", got.Scripts[0].Stdout, got.Scripts[0].Stderr)
+	}
+	if got.Scripts[0].StdoutTruncated != 0 || got.Scripts[0].StderrTruncated != 4096 {
+		t.Errorf("unexpected truncated bytes: %d, %d", got.Scripts[0].StdoutTruncated, got.Scripts[0].StderrTruncated)
+	}
+	if !strings.Contains(string(data), `"stderrTruncated": 4096`) {
+		t.Errorf("expected the stderr truncated bytes in %s", data)
+	}
+	if !reflect.DeepEqual(got.Scripts[0].Artifacts, r.Scripts[0].Artifacts) {
+		t.Errorf("unexpected artifacts:\n\t(WNT) %v\n\t(GOT) %v", r.Scripts[0].Artifacts, got.Scripts[0].Artifacts)
+	}
+	wantSummary := []string{"1 scripts, 1 warning", "warning: 01-lio: wrote to stderr"}
+	if !reflect.DeepEqual(got.Summary, wantSummary) {
+		t.Errorf("unexpected summary:\n\t(WNT) %q\n\t(GOT) %q", wantSummary, got.Summary)
+	}
+}
+
+func TestReadFile(t *testing.T) {
+	r := New("storageos/node:test")
+	r.AddScript(script.Script{Name: "01-lio", RelPath: "01-lio/enable-lio.sh"}, time.Now(), &script.Result{}, nil)
+	r.Finish()
+
+	path := filepath.Join(t.TempDir(), "report.json")
+	if err := r.WriteFile(path); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got, err := ReadFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.NodeImage != r.NodeImage || len(got.Scripts) != 1 || got.Scripts[0].RelPath != "01-lio/enable-lio.sh" || got.Scripts[0].Status != StatusPassed {
+		t.Errorf("unexpected report: %+v", got)
+	}
+
+	if err := ioutil.WriteFile(path, []byte("{"), 0644); err != nil {
+		t.Fatalf("failed to write report: %v", err)
+	}
+	if _, err := ReadFile(path); err == nil {
+		t.Error("expected an error for an invalid report")
+	}
+}
+
+func TestMerge(t *testing.T) {
+	full := New("storageos/node:old")
+	full.AddScript(script.Script{Name: "01-lio", RelPath: "01-lio/enable-lio.sh"}, time.Now(), &script.Result{}, nil)
+	full.AddScript(script.Script{Name: "03-lio-check", RelPath: "03-lio-check/check.sh"}, time.Now(), &script.Result{}, nil)
+	full.AddScript(script.Script{Name: "04-limits", RelPath: "04-limits/check.sh"}, time.Now(), &script.Result{}, nil)
+	full.Finish()
+
+	recheck := New("storageos/node:new")
+	recheck.AddScript(script.Script{Name: "03-lio-check", RelPath: "03-lio-check/check.sh"}, time.Now(), &script.Result{ExitCode: 1}, errors.New("exit status 1"))
+	recheck.AddScript(script.Script{Name: "05-new", RelPath: "05-new/check.sh"}, time.Now(), &script.Result{}, nil)
+	recheck.Finish()
+
+	merged := full.Merge(recheck)
+
+	wantScripts := []string{"01-lio: passed", "03-lio-check: failed", "04-limits: passed", "05-new: passed"}
+	gotScripts := []string{}
+	for _, s := range merged.Scripts {
+		gotScripts = append(gotScripts, s.Name+": "+string(s.Status))
+	}
+	if !reflect.DeepEqual(gotScripts, wantScripts) {
+		t.Errorf("unexpected scripts:\n\t(WNT) %q\n\t(GOT) %q", wantScripts, gotScripts)
+	}
+	if merged.NodeImage != recheck.NodeImage || !merged.Start.Equal(recheck.Start) || !merged.End.Equal(recheck.End) {
+		t.Errorf("expected the recheck image and times, got %+v", merged)
+	}
+	wantSummary := []string{"4 scripts, 3 passed, 1 failed", "failed: 03-lio-check: exited 1"}
+	if !reflect.DeepEqual(merged.Summary, wantSummary) {
+		t.Errorf("unexpected summary:\n\t(WNT) %q\n\t(GOT) %q", wantSummary, merged.Summary)
+	}
+	if merged.Success() {
+		t.Error("expected the merged report to fail")
+	}
+
+	// The base report is left as is.
+	if len(full.Scripts) != 3 || full.Scripts[1].Status != StatusPassed {
+		t.Errorf("unexpected base report change: %+v", full.Scripts)
+	}
+}
@@ -0,0 +1,90 @@
+package script
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// CleanupName is the name, without the extension, of the companion
+	// cleanup script in a script directory, e.g. 01-lio/cleanup.sh.
+	CleanupName = "cleanup"
+	// CleanupShell is the shell that runs the manifest cleanup commands.
+	CleanupShell = "/bin/sh"
+)
+
+// isCleanupScript returns true if the file at path relative to the scripts
+// directory is a companion cleanup script. Only the scripts in a
+// subdirectory can have a companion cleanup script.
+func isCleanupScript(rel string) bool {
+	base := filepath.Base(rel)
+	return filepath.Dir(rel) != "." && strings.TrimSuffix(base, filepath.Ext(base)) == CleanupName
+}
+
+// GetCleanupScripts returns the cleanup actions of a list of scripts, in the
+// reverse order of the scripts. The cleanup action of a script is the
+// manifest cleanup command, run with the CleanupShell command of the system
+// the script runs on, e.g. the host shell for a script in the host
+// namespaces, or the companion cleanup script in the script directory.
+// Scripts without a cleanup action are ignored. Each cleanup script has the
+// name, source, manifest, env vars and skip reason of the script it undoes.
+func GetCleanupScripts(scripts []Script) ([]Script, error) {
+	cleanups := []Script{}
+	seen := map[string]bool{}
+
+	for i := len(scripts) - 1; i >= 0; i-- {
+		s := scripts[i]
+		// All the scripts with the same name share the script directory.
+		if seen[s.Name] {
+			continue
+		}
+		seen[s.Name] = true
+
+		companion, err := findCleanupScript(s)
+		if err != nil {
+			return nil, err
+		}
+
+		cleanup := s
+		switch {
+		case s.Manifest.Cleanup != "" && companion != "":
+			return nil, fmt.Errorf("script %s has both a manifest cleanup command and a cleanup script", s.Name)
+		case s.Manifest.Cleanup != "":
+			cleanup.Path = CleanupShell
+			cleanup.RelPath = filepath.Join(filepath.Dir(s.RelPath), ManifestFile)
+			cleanup.Args = []string{"-c", s.Manifest.Cleanup}
+			cleanup.Command = true
+		case companion != "":
+			cleanup.Path = companion
+			cleanup.RelPath = filepath.Join(filepath.Dir(s.RelPath), filepath.Base(companion))
+			cleanup.Args = nil
+		default:
+			continue
+		}
+		cleanups = append(cleanups, cleanup)
+	}
+
+	return cleanups, nil
+}
+
+// findCleanupScript returns the path of the companion cleanup script of a
+// script, or an empty string if there's none.
+func findCleanupScript(s Script) (string, error) {
+	if filepath.Dir(s.RelPath) == "." {
+		return "", nil
+	}
+
+	dir := filepath.Dir(s.Path)
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+	for _, f := range files {
+		if !f.IsDir() && isCleanupScript(filepath.Join(s.Name, f.Name())) && !docFileExt[filepath.Ext(f.Name())] {
+			return filepath.Join(dir, f.Name()), nil
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,77 @@
+package script
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestGetCleanupScripts(t *testing.T) {
+	scriptsDir, err := ioutil.TempDir("", "init-cleanup-test")
+	if err != nil {
+		t.Fatalf("failed to create directory: %v", err)
+	}
+	defer os.RemoveAll(scriptsDir)
+
+	files := map[string]string{
+		"01-lio/enable-lio.sh":   "",
+		"01-lio/cleanup.sh":      "",
+		"01-lio/cleanup.md":      "docs",
+		"02-limits/limits.sh":    "",
+		"03-mount/mount.sh":      "",
+		"03-mount/manifest.yaml": "cleanup: umount /mnt/foo\n",
+		"04-check.sh":            "",
+	}
+	for name, content := range files {
+		path := filepath.Join(scriptsDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+			t.Fatalf("failed to create sub directory: %v", err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0755); err != nil {
+			t.Fatalf("failed to create file: %v", err)
+		}
+	}
+
+	scripts, err := GetAllScripts(scriptsDir)
+	if err != nil {
+		t.Fatalf("failed to get all scripts: %v", err)
+	}
+
+	cleanups, err := GetCleanupScripts(scripts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []struct {
+		name    string
+		path    string
+		args    []string
+		command bool
+	}{
+		{name: "03-mount", path: CleanupShell, args: []string{"-c", "umount /mnt/foo"}, command: true},
+		{name: "01-lio", path: filepath.Join(scriptsDir, "01-lio/cleanup.sh")},
+	}
+
+	if len(cleanups) != len(want) {
+		t.Fatalf("unexpected number of cleanup scripts:\n\t(WNT) %d\n\t(GOT) %d", len(want), len(cleanups))
+	}
+	for i, w := range want {
+		c := cleanups[i]
+		if c.Name != w.name || c.Path != w.path || !reflect.DeepEqual(c.Args, w.args) || c.Command != w.command {
+			t.Errorf("unexpected cleanup script %d:\n\t(WNT) %s %s %v %t\n\t(GOT) %s %s %v %t", i, w.name, w.path, w.args, w.command, c.Name, c.Path, c.Args, c.Command)
+		}
+	}
+	if cleanups[0].String() != "/bin/sh -c umount /mnt/foo" {
+		t.Errorf("unexpected cleanup script string: %s", cleanups[0])
+	}
+
+	// A script can't have both cleanup actions.
+	if err := ioutil.WriteFile(filepath.Join(scriptsDir, "03-mount/cleanup.sh"), nil, 0755); err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+	if _, err := GetCleanupScripts(scripts); err == nil {
+		t.Error("expected error for script with two cleanup actions")
+	}
+}
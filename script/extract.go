@@ -0,0 +1,53 @@
+package script
+
+import (
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// Modes of the extracted files.
+const (
+	extractDirMode    = 0755
+	extractScriptMode = 0755
+	extractDataMode   = 0644
+)
+
+// Extract writes the scripts directory of a file system, e.g. the scripts
+// embedded in the binary, into dir. The file system doesn't record the file
+// modes: the scripts, including the cleanup scripts, are made executable, the
+// docs, manifests, checksums and tombstone files are not. The modes are set
+// regardless of the umask.
+func Extract(fsys fs.FS, dir string) error {
+	return fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		target := filepath.Join(dir, filepath.FromSlash(path))
+		if path == "." {
+			// Keep the mode of dir, e.g. a private temporary directory.
+			return os.MkdirAll(target, extractDirMode)
+		}
+		if d.IsDir() {
+			if err := os.Mkdir(target, extractDirMode); err != nil && !os.IsExist(err) {
+				return err
+			}
+			return os.Chmod(target, extractDirMode)
+		}
+
+		data, err := fs.ReadFile(fsys, path)
+		if err != nil {
+			return err
+		}
+		var mode os.FileMode = extractScriptMode
+		if isDataFile(d.Name()) {
+			mode = extractDataMode
+		}
+		if err := ioutil.WriteFile(target, data, mode); err != nil {
+			return err
+		}
+		return os.Chmod(target, mode)
+	})
+}
@@ -0,0 +1,65 @@
+package script
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"testing/fstest"
+)
+
+func TestExtract(t *testing.T) {
+	dir, err := ioutil.TempDir("", "init-extract-test")
+	if err != nil {
+		t.Fatalf("failed to create directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Extract with a restrictive umask, the modes must not depend on it.
+	defer syscall.Umask(syscall.Umask(0077))
+
+	fsys := fstest.MapFS{
+		"01-lio/enable-lio.sh":   {Data: []byte("#!/bin/bash\n")},
+		"01-lio/cleanup.sh":      {Data: []byte("#!/bin/bash\n")},
+		"01-lio/README.md":       {Data: []byte("docs")},
+		"01-lio/manifest.yaml":   {Data: []byte("recheck: true\n")},
+		"02-limits/limits.sh":    {Data: []byte("#!/bin/bash\n")},
+		"03-check.sh":            {Data: []byte("#!/bin/bash\n")},
+		"SHA256SUMS":             {Data: []byte("")},
+		"04-old/old.sh.disabled": {Data: []byte("")},
+	}
+	if err := Extract(fsys, dir); err != nil {
+		t.Fatalf("failed to extract: %v", err)
+	}
+
+	wantModes := map[string]os.FileMode{
+		"01-lio":                 os.ModeDir | 0755,
+		"01-lio/enable-lio.sh":   0755,
+		"01-lio/cleanup.sh":      0755,
+		"01-lio/README.md":       0644,
+		"01-lio/manifest.yaml":   0644,
+		"02-limits/limits.sh":    0755,
+		"03-check.sh":            0755,
+		"SHA256SUMS":             0644,
+		"04-old/old.sh.disabled": 0644,
+	}
+	for name, wantMode := range wantModes {
+		info, err := os.Stat(filepath.Join(dir, name))
+		if err != nil {
+			t.Errorf("file %s not extracted: %v", name, err)
+			continue
+		}
+		if info.Mode() != wantMode {
+			t.Errorf("unexpected mode of %s:\n\t(WNT) %s\n\t(GOT) %s", name, wantMode, info.Mode())
+		}
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "01-lio/manifest.yaml"))
+	if err != nil {
+		t.Fatalf("failed to read extracted file: %v", err)
+	}
+	if string(data) != "recheck: true\n" {
+		t.Errorf("unexpected content:\n\t(WNT) %q\n\t(GOT) %q", "recheck: true\n", string(data))
+	}
+}
@@ -0,0 +1,84 @@
+package script
+
+import (
+	"fmt"
+	"path"
+)
+
+// Filter selects the scripts to run by name, name glob pattern or manifest
+// tag.
+type Filter struct {
+	only []string
+	skip []string
+}
+
+// NewFilter returns a Filter that runs only the scripts matching any of the
+// only patterns, if any, and skips the scripts matching any of the skip
+// patterns. A pattern matches a script if it matches its name, as a glob
+// pattern, e.g. "0*-lio", or one of its manifest tags.
+func NewFilter(only, skip []string) (*Filter, error) {
+	for _, pattern := range append(append([]string{}, only...), skip...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid script pattern %q: %v", pattern, err)
+		}
+	}
+	return &Filter{only: only, skip: skip}, nil
+}
+
+// Apply marks the scripts filtered out as skipped, with the reason. The
+// scripts already skipped keep their reason. It returns the patterns that
+// match no script, likely typos.
+func (f *Filter) Apply(scripts []Script) []string {
+	matched := map[string]bool{}
+
+	for i, s := range scripts {
+		onlyMatch := len(f.only) == 0
+		for _, pattern := range f.only {
+			if Matches(s, pattern) {
+				matched[pattern] = true
+				onlyMatch = true
+			}
+		}
+		skipPattern := ""
+		for _, pattern := range f.skip {
+			if Matches(s, pattern) {
+				matched[pattern] = true
+				if skipPattern == "" {
+					skipPattern = pattern
+				}
+			}
+		}
+
+		if s.Skip != "" {
+			continue
+		}
+		switch {
+		case !onlyMatch:
+			scripts[i].Skip = "not selected by -only"
+		case skipPattern != "":
+			scripts[i].Skip = fmt.Sprintf("skipped by -skip %s", skipPattern)
+		}
+	}
+
+	unmatched := []string{}
+	for _, pattern := range append(append([]string{}, f.only...), f.skip...) {
+		if !matched[pattern] {
+			unmatched = append(unmatched, pattern)
+		}
+	}
+	return unmatched
+}
+
+// Matches returns true if a pattern matches the name or one of the manifest
+// tags of a script.
+func Matches(s Script, pattern string) bool {
+	if ok, _ := path.Match(pattern, s.Name); ok {
+		return true
+	}
+	for _, tag := range s.Manifest.Tags {
+		if ok, _ := path.Match(pattern, tag); ok {
+			return true
+		}
+	}
+	return false
+}
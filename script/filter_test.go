@@ -0,0 +1,101 @@
+package script
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFilter(t *testing.T) {
+	testcases := []struct {
+		name          string
+		only          []string
+		skip          []string
+		wantSkip      map[string]string
+		wantUnmatched []string
+		wantErr       bool
+	}{
+		{
+			name:          "no filter",
+			wantSkip:      map[string]string{"03-mount": "skipped by config file"},
+			wantUnmatched: []string{},
+		},
+		{
+			name: "only name",
+			only: []string{"02-limits"},
+			wantSkip: map[string]string{
+				"01-lio":   "not selected by -only",
+				"03-mount": "skipped by config file",
+				"04-check": "not selected by -only",
+			},
+			wantUnmatched: []string{},
+		},
+		{
+			name: "only tag and glob",
+			only: []string{"advisory", "0?-lio"},
+			wantSkip: map[string]string{
+				"02-limits": "not selected by -only",
+				"03-mount":  "skipped by config file",
+			},
+			wantUnmatched: []string{},
+		},
+		{
+			name: "skip tag",
+			skip: []string{"kernel"},
+			wantSkip: map[string]string{
+				"01-lio":   "skipped by -skip kernel",
+				"03-mount": "skipped by config file",
+			},
+			wantUnmatched: []string{},
+		},
+		{
+			name: "only and skip",
+			only: []string{"kernel", "limits"},
+			skip: []string{"01-*", "foo"},
+			wantSkip: map[string]string{
+				"01-lio":   "skipped by -skip 01-*",
+				"03-mount": "skipped by config file",
+				"04-check": "not selected by -only",
+			},
+			wantUnmatched: []string{"foo"},
+		},
+		{
+			name:    "invalid pattern",
+			skip:    []string{"[01-lio"},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			scripts := []Script{
+				{Name: "01-lio", Manifest: Manifest{Tags: []string{"kernel"}}},
+				{Name: "02-limits", Manifest: Manifest{Tags: []string{"limits"}}},
+				{Name: "03-mount", Manifest: Manifest{Tags: []string{"kernel"}}, Skip: "skipped by config file"},
+				{Name: "04-check", Manifest: Manifest{Tags: []string{"advisory"}}},
+			}
+
+			f, err := NewFilter(tc.only, tc.skip)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if err != nil {
+				return
+			}
+
+			unmatched := f.Apply(scripts)
+			if !reflect.DeepEqual(unmatched, tc.wantUnmatched) {
+				t.Errorf("unexpected unmatched patterns:\n\t(WNT) %v\n\t(GOT) %v", tc.wantUnmatched, unmatched)
+			}
+
+			gotSkip := map[string]string{}
+			for _, s := range scripts {
+				if s.Skip != "" {
+					gotSkip[s.Name] = s.Skip
+				}
+			}
+			if !reflect.DeepEqual(gotSkip, tc.wantSkip) {
+				t.Errorf("unexpected skipped scripts:\n\t(WNT) %v\n\t(GOT) %v", tc.wantSkip, gotSkip)
+			}
+		})
+	}
+}
@@ -0,0 +1,220 @@
+# StorageOS init script helper library.
+#
+# Provided by init to the scripts through the INIT_LIB env var. Source it at
+# the top of a bash script with:
+#
+#   . "${INIT_LIB:?INIT_LIB not set, run the script with init}"
+#
+# All the functions are prefixed with init_. Paths on the host are prefixed
+# with HOST_ROOT, empty when the script runs in the host namespaces.
+
+# Don't source the library twice.
+if [ -n "${INIT_LIB_VERSION:-}" ]; then
+    return 0
+fi
+
+# INIT_LIB_VERSION is the version of the library, incremented on incompatible
+# changes.
+INIT_LIB_VERSION=1
+
+# INIT_LOG_LEVEL is the minimum level of the logged messages, one of debug,
+# info, warn or error.
+INIT_LOG_LEVEL="${INIT_LOG_LEVEL:-info}"
+
+# INIT_PROC_CGROUP is the cgroup membership file of the script process.
+INIT_PROC_CGROUP="${INIT_PROC_CGROUP:-/proc/self/cgroup}"
+
+# INIT_CGROUP_ROOT is the cgroup filesystem the cgroups of INIT_PROC_CGROUP
+# are relative to: the one of the container, not of HOST_ROOT, as the cgroups
+# are relative to the cgroup namespace of the script.
+INIT_CGROUP_ROOT="${INIT_CGROUP_ROOT:-/sys/fs/cgroup}"
+
+# _init_level_num returns the severity of a log level.
+function _init_level_num() {
+    case "$1" in
+    debug) echo 0 ;;
+    warn) echo 2 ;;
+    error) echo 3 ;;
+    *) echo 1 ;;
+    esac
+}
+
+# _init_log logs a message with a level. Warnings and errors are written to
+# stderr, which gives the script a warning status if it exits successfully.
+function _init_log() {
+    local level="$1"
+    shift
+    if [ "$(_init_level_num "$level")" -lt "$(_init_level_num "$INIT_LOG_LEVEL")" ]; then
+        return 0
+    fi
+    case "$level" in
+    debug) echo "DEBUG: $*" ;;
+    info) echo "INFO: $*" ;;
+    warn) echo -e "\e[0;33mWARNING\e[0m: $*" >&2 ;;
+    error) echo -e "\e[0;31mERROR\e[0m: $*" >&2 ;;
+    esac
+}
+
+# init_log_debug, init_log_info, init_log_warn and init_log_error log a message
+# at their level.
+function init_log_debug() { _init_log debug "$@"; }
+function init_log_info() { _init_log info "$@"; }
+function init_log_warn() { _init_log warn "$@"; }
+function init_log_error() { _init_log error "$@"; }
+
+# init_result writes a key=value line to the result file of the script, if
+# any. Newlines in the value are replaced with spaces.
+function init_result() {
+    local key="$1" value="$2"
+    if [ -z "${INIT_RESULT_FILE:-}" ]; then
+        return 0
+    fi
+    echo "${key}=${value//$'\n'/ }" >>"$INIT_RESULT_FILE"
+}
+
+# init_fact, init_metric and init_output report a named fact, metric or output
+# in the result file of the script.
+function init_fact() { init_result "fact.$1" "$2"; }
+function init_metric() { init_result "metric.$1" "$2"; }
+function init_output() { init_result "output.$1" "$2"; }
+
+# init_warning logs a warning and reports the script with a warning status,
+# with an optional remediation hint.
+function init_warning() {
+    local message="$1" remediation="${2:-}"
+    init_log_warn "$message"
+    init_result status warning
+    init_result message "$message"
+    if [ -n "$remediation" ]; then
+        init_result remediation "$remediation"
+    fi
+}
+
+# init_skip reports the script as skipped with a reason and exits
+# successfully.
+function init_skip() {
+    local reason="$1"
+    init_log_info "skipping: $reason"
+    init_result status skipped
+    init_result message "$reason"
+    exit 0
+}
+
+# init_fail logs an error, reports the script as failed with an optional
+# remediation hint and exits with an error.
+function init_fail() {
+    local message="$1" remediation="${2:-}"
+    init_log_error "$message"
+    init_result status failed
+    init_result message "$message"
+    if [ -n "$remediation" ]; then
+        init_result remediation "$remediation"
+    fi
+    exit 1
+}
+
+# init_require_env fails the script if any of the given env vars is not set
+# or empty.
+function init_require_env() {
+    local name
+    for name in "$@"; do
+        if [ -z "${!name:-}" ]; then
+            init_fail "required env var $name is not set"
+        fi
+    done
+}
+
+# init_artifact runs a command and saves its stdout and stderr to a file of the
+# artifacts directory of the script, preserved after the script runs, e.g.
+# init_artifact lsmod.txt lsmod. A command failure is logged at the debug level
+# and ignored.
+function init_artifact() {
+    local file="$1"
+    shift
+    if [ -z "${INIT_ARTIFACTS:-}" ]; then
+        return 0
+    fi
+    mkdir -p "$(dirname "$INIT_ARTIFACTS/$file")"
+    if ! "$@" >"$INIT_ARTIFACTS/$file" 2>&1; then
+        init_log_debug "artifact $file: command failed: $*"
+    fi
+    return 0
+}
+
+# init_module_loaded returns successfully if a kernel module is loaded and
+# running on the host.
+function init_module_loaded() {
+    local state_file="${HOST_ROOT:-}/sys/module/$1/initstate"
+    [ -f "$state_file" ] && grep -q live "$state_file"
+}
+
+# init_module_load loads a kernel module with modprobe if it's not loaded
+# already. It returns the modprobe exit status.
+function init_module_load() {
+    local mod="$1"
+    if init_module_loaded "$mod"; then
+        init_log_info "module $mod is running"
+        return 0
+    fi
+    init_log_info "module $mod is not running, executing: modprobe -b $mod"
+    modprobe -b "$mod"
+}
+
+# init_module_persist adds a kernel module to a modules-load.d config file of
+# the host, e.g. lio.conf, to load it at boot.
+function init_module_persist() {
+    local mod="$1" conf="$2"
+    local dir="${HOST_ROOT:-}/etc/modules-load.d"
+    mkdir -p "$dir"
+    if [ -f "$dir/$conf" ] && grep -qx "$mod" "$dir/$conf"; then
+        return 0
+    fi
+    echo "$mod" >>"$dir/$conf"
+}
+
+# init_cgroup_limit prints the effective limit of a cgroup controller file for
+# the script, e.g. init_cgroup_limit pids pids.max, the lowest numeric value
+# from the script cgroup up to the root. It returns an error if the limit is
+# unlimited or can't be determined.
+function init_cgroup_limit() {
+    local controller="$1" file="$2"
+    local limit="" line slice prefix dir value
+
+    # Use the cgroup v1 controller hierarchy if any, the unified v2 hierarchy
+    # otherwise.
+    line=$(grep -E "^[0-9]+:([^:]*,)?${controller}(,[^:]*)?:" "$INIT_PROC_CGROUP" | head -n 1)
+    prefix="${INIT_CGROUP_ROOT}/${controller}"
+    if [ -z "$line" ]; then
+        line=$(grep "^0::" "$INIT_PROC_CGROUP" | head -n 1)
+        prefix="${INIT_CGROUP_ROOT}"
+    fi
+    if [ -z "$line" ]; then
+        return 1
+    fi
+
+    # The slice field, <id>:<controllers>:<slice>, can have a prefix that is
+    # not part of the directory path, e.g. in a cgroup namespace. Strip it
+    # until the slice directory is found.
+    slice="${line#*:*:}"
+    while [ ! -d "${prefix}/${slice}" ]; do
+        if [[ "$slice" != */* ]]; then
+            return 1
+        fi
+        slice="${slice#*/}"
+    done
+
+    # Traverse up the hierarchy, the lowest limit is the effective one.
+    dir="${prefix}/${slice}"
+    while [ -f "${dir}/${file}" ]; do
+        value=$(<"${dir}/${file}")
+        if [[ "$value" =~ ^[0-9]+$ ]] && { [ -z "$limit" ] || [ "$value" -lt "$limit" ]; }; then
+            limit="$value"
+        fi
+        dir="${dir}/.."
+    done
+
+    if [ -z "$limit" ]; then
+        return 1
+    fi
+    echo "$limit"
+}
@@ -0,0 +1,39 @@
+// Package lib provides the bash helper library of the scripts, embedded in the
+// init binary.
+package lib
+
+import (
+	_ "embed"
+	"io/ioutil"
+	"path/filepath"
+)
+
+const (
+	// EnvVar is the env var that contains the path of the library passed to
+	// the scripts.
+	EnvVar = "INIT_LIB"
+	// Version is the version of the library, INIT_LIB_VERSION in the
+	// library.
+	Version = 1
+	// FileName is the name of the installed library file.
+	FileName = "init-lib.sh"
+)
+
+// source is the source of the library.
+//
+//go:embed init.sh
+var source string
+
+// Source returns the source of the library.
+func Source() []byte {
+	return []byte(source)
+}
+
+// Install writes the library to a directory and returns its path.
+func Install(dir string) (string, error) {
+	path := filepath.Join(dir, FileName)
+	if err := ioutil.WriteFile(path, Source(), 0644); err != nil {
+		return "", err
+	}
+	return path, nil
+}
@@ -0,0 +1,326 @@
+package lib
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// scriptRun is the outcome of a bash script run with the library.
+type scriptRun struct {
+	stdout   string
+	stderr   string
+	exitCode int
+	result   string
+}
+
+// runWithLib runs a bash command, or a script file with file set, with the
+// library installed and the given env vars, in a temporary directory.
+func runWithLib(t *testing.T, command string, file bool, env ...string) scriptRun {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "init-lib-test")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	libPath, err := Install(dir)
+	if err != nil {
+		t.Fatalf("failed to install library: %v", err)
+	}
+	resultFile := filepath.Join(dir, "result")
+
+	args := []string{"-c", `. "$INIT_LIB"` + "\n" + command}
+	if file {
+		args = []string{command}
+	}
+	cmd := exec.Command("bash", args...)
+	cmd.Env = append([]string{
+		"PATH=" + os.Getenv("PATH"),
+		EnvVar + "=" + libPath,
+		"INIT_RESULT_FILE=" + resultFile,
+	}, env...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	run := scriptRun{}
+	if err := cmd.Run(); err != nil {
+		exitErr, ok := err.(*exec.ExitError)
+		if !ok {
+			t.Fatalf("failed to run bash: %v", err)
+		}
+		run.exitCode = exitErr.ExitCode()
+	}
+	run.stdout = stdout.String()
+	run.stderr = stderr.String()
+
+	result, _ := ioutil.ReadFile(resultFile)
+	run.result = string(result)
+	return run
+}
+
+func TestSource(t *testing.T) {
+	data, err := ioutil.ReadFile("init.sh")
+	if err != nil {
+		t.Fatalf("failed to read library: %v", err)
+	}
+	if !bytes.Equal(data, Source()) {
+		t.Errorf("embedded library doesn't match init.sh")
+	}
+	if !bytes.Contains(data, []byte(fmt.Sprintf("\nINIT_LIB_VERSION=%d\n", Version))) {
+		t.Errorf("library version doesn't match %d", Version)
+	}
+}
+
+func TestLibrary(t *testing.T) {
+	// Fake host root with a loaded and an unloaded module.
+	hostRoot, err := ioutil.TempDir("", "init-lib-host")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(hostRoot)
+	writeFile(t, filepath.Join(hostRoot, "sys/module/uio/initstate"), "live\n")
+	writeFile(t, filepath.Join(hostRoot, "sys/module/tcm_loop/initstate"), "coming\n")
+
+	testcases := []struct {
+		name         string
+		command      string
+		env          []string
+		wantStdout   string
+		wantStderr   string
+		wantExitCode int
+		wantResult   string
+	}{
+		{
+			name:       "log levels",
+			command:    "init_log_debug a; init_log_info b; init_log_warn c; init_log_error d",
+			wantStdout: "INFO: b\n",
+			wantStderr: "\x1b[0;33mWARNING\x1b[0m: c\n\x1b[0;31mERROR\x1b[0m: d\n",
+		},
+		{
+			name:       "debug log level",
+			command:    "init_log_debug a",
+			env:        []string{"INIT_LOG_LEVEL=debug"},
+			wantStdout: "DEBUG: a\n",
+		},
+		{
+			name:       "error log level",
+			command:    "init_log_info a; init_log_warn b",
+			env:        []string{"INIT_LOG_LEVEL=error"},
+			wantStdout: "",
+		},
+		{
+			name:       "facts metrics and outputs",
+			command:    "init_fact kernel 5.4; init_metric max_pids 512; init_output FOO $'a\\nb'",
+			wantResult: "fact.kernel=5.4\nmetric.max_pids=512\noutput.FOO=a b\n",
+		},
+		{
+			name:       "warning",
+			command:    "init_warning 'uio missing' 'modprobe uio'",
+			wantStderr: "\x1b[0;33mWARNING\x1b[0m: uio missing\n",
+			wantResult: "status=warning\nmessage=uio missing\nremediation=modprobe uio\n",
+		},
+		{
+			name:       "skip",
+			command:    "init_skip 'not needed'; echo unreachable",
+			wantStdout: "INFO: skipping: not needed\n",
+			wantResult: "status=skipped\nmessage=not needed\n",
+		},
+		{
+			name:         "fail",
+			command:      "init_fail 'no configfs'; echo unreachable",
+			wantStderr:   "\x1b[0;31mERROR\x1b[0m: no configfs\n",
+			wantExitCode: 1,
+			wantResult:   "status=failed\nmessage=no configfs\n",
+		},
+		{
+			name:       "required env set",
+			command:    "init_require_env FOO",
+			env:        []string{"FOO=bar"},
+			wantResult: "",
+		},
+		{
+			name:         "required env not set",
+			command:      "init_require_env FOO BAR",
+			env:          []string{"FOO=bar"},
+			wantStderr:   "\x1b[0;31mERROR\x1b[0m: required env var BAR is not set\n",
+			wantExitCode: 1,
+			wantResult:   "status=failed\nmessage=required env var BAR is not set\n",
+		},
+		{
+			name:       "module loaded",
+			command:    "init_module_loaded uio && echo uio; init_module_loaded tcm_loop || echo no tcm_loop; init_module_loaded foo || echo no foo",
+			env:        []string{"HOST_ROOT=" + hostRoot},
+			wantStdout: "uio\nno tcm_loop\nno foo\n",
+		},
+		{
+			name:       "load loaded module",
+			command:    "init_module_load uio",
+			env:        []string{"HOST_ROOT=" + hostRoot},
+			wantStdout: "INFO: module uio is running\n",
+		},
+		{
+			name:       "library sourced twice",
+			command:    `INIT_LOG_LEVEL=error; . "$INIT_LIB"; init_log_info a`,
+			wantStdout: "",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			run := runWithLib(t, tc.command, false, tc.env...)
+
+			if run.stdout != tc.wantStdout {
+				t.Errorf("unexpected stdout:\n\t(WNT) %q\n\t(GOT) %q", tc.wantStdout, run.stdout)
+			}
+			if run.stderr != tc.wantStderr {
+				t.Errorf("unexpected stderr:\n\t(WNT) %q\n\t(GOT) %q", tc.wantStderr, run.stderr)
+			}
+			if run.exitCode != tc.wantExitCode {
+				t.Errorf("unexpected exit code:\n\t(WNT) %d\n\t(GOT) %d", tc.wantExitCode, run.exitCode)
+			}
+			if run.result != tc.wantResult {
+				t.Errorf("unexpected result file:\n\t(WNT) %q\n\t(GOT) %q", tc.wantResult, run.result)
+			}
+		})
+	}
+}
+
+func TestModulePersist(t *testing.T) {
+	hostRoot, err := ioutil.TempDir("", "init-lib-host")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(hostRoot)
+
+	run := runWithLib(t, "init_module_persist uio lio.conf; init_module_persist tcm_loop lio.conf; init_module_persist uio lio.conf", false, "HOST_ROOT="+hostRoot)
+	if run.exitCode != 0 {
+		t.Fatalf("unexpected exit code %d: %s", run.exitCode, run.stderr)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(hostRoot, "etc/modules-load.d/lio.conf"))
+	if err != nil {
+		t.Fatalf("failed to read modules config: %v", err)
+	}
+	want := "uio\ntcm_loop\n"
+	if string(data) != want {
+		t.Errorf("unexpected modules config:\n\t(WNT) %q\n\t(GOT) %q", want, string(data))
+	}
+}
+
+func TestCgroupLimit(t *testing.T) {
+	testcases := []struct {
+		name         string
+		procCgroup   string
+		files        map[string]string
+		wantStdout   string
+		wantExitCode int
+	}{
+		{
+			name:       "v1 lowest limit in the hierarchy",
+			procCgroup: "12:pids:/kubepods/pod1/c1\n11:memory:/kubepods/pod1/c1\n",
+			files: map[string]string{
+				"sys/fs/cgroup/pids/kubepods/pids.max":         "4096\n",
+				"sys/fs/cgroup/pids/kubepods/pod1/pids.max":    "max\n",
+				"sys/fs/cgroup/pids/kubepods/pod1/c1/pids.max": "8192\n",
+			},
+			wantStdout: "4096\n",
+		},
+		{
+			name:       "v1 slice prefix stripped",
+			procCgroup: "12:pids:/docker/abc/kubepods/pod1\n",
+			files: map[string]string{
+				"sys/fs/cgroup/pids/kubepods/pod1/pids.max": "1024\n",
+			},
+			wantStdout: "1024\n",
+		},
+		{
+			name:       "v2 unified hierarchy",
+			procCgroup: "0::/kubepods/pod1\n",
+			files: map[string]string{
+				"sys/fs/cgroup/kubepods/pids.max":      "2048\n",
+				"sys/fs/cgroup/kubepods/pod1/pids.max": "max\n",
+			},
+			wantStdout: "2048\n",
+		},
+		{
+			name:       "unlimited",
+			procCgroup: "12:pids:/kubepods\n",
+			files: map[string]string{
+				"sys/fs/cgroup/pids/kubepods/pids.max": "max\n",
+			},
+			wantExitCode: 1,
+		},
+		{
+			name:         "no cgroup dir",
+			procCgroup:   "12:pids:/kubepods\n",
+			wantExitCode: 1,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			hostRoot, err := ioutil.TempDir("", "init-lib-host")
+			if err != nil {
+				t.Fatalf("failed to create temp dir: %v", err)
+			}
+			defer os.RemoveAll(hostRoot)
+			for name, content := range tc.files {
+				writeFile(t, filepath.Join(hostRoot, name), content)
+			}
+			procCgroup := filepath.Join(hostRoot, "cgroup")
+			writeFile(t, procCgroup, tc.procCgroup)
+
+			// The cgroups are not resolved under the host root.
+			run := runWithLib(t, "init_cgroup_limit pids pids.max", false, "HOST_ROOT=/nonexistent", "INIT_CGROUP_ROOT="+filepath.Join(hostRoot, "sys/fs/cgroup"), "INIT_PROC_CGROUP="+procCgroup)
+			if run.stdout != tc.wantStdout {
+				t.Errorf("unexpected stdout:\n\t(WNT) %q\n\t(GOT) %q", tc.wantStdout, run.stdout)
+			}
+			if run.exitCode != tc.wantExitCode {
+				t.Errorf("unexpected exit code:\n\t(WNT) %d\n\t(GOT) %d", tc.wantExitCode, run.exitCode)
+			}
+		})
+	}
+}
+
+// writeFile writes a file, creating its parent directories.
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("failed to create dir: %v", err)
+	}
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+}
+
+func TestArtifact(t *testing.T) {
+	artifacts := t.TempDir()
+
+	run := runWithLib(t, "init_artifact logs/echo.txt echo foo; init_artifact failed.txt false", false, "INIT_ARTIFACTS="+artifacts)
+	if run.exitCode != 0 {
+		t.Fatalf("unexpected exit code %d: %s", run.exitCode, run.stderr)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(artifacts, "logs/echo.txt"))
+	if err != nil {
+		t.Fatalf("failed to read artifact: %v", err)
+	}
+	if string(data) != "foo\n" {
+		t.Errorf("unexpected artifact:\n\t(WNT) %q\n\t(GOT) %q", "foo\n", string(data))
+	}
+	if _, err := os.Stat(filepath.Join(artifacts, "failed.txt")); err != nil {
+		t.Errorf("expected the failed command artifact: %v", err)
+	}
+
+	// Without an artifacts directory, the command is not run.
+	run = runWithLib(t, "init_artifact echo.txt echo foo", false)
+	if run.exitCode != 0 || run.stdout != "" {
+		t.Errorf("unexpected run: %+v", run)
+	}
+}
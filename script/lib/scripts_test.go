@@ -0,0 +1,193 @@
+package lib
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Stock scripts ported onto the library.
+const (
+	enableLIOScript = "../../scripts/01-lio/enable-lio.sh"
+	limitsScript    = "../../scripts/02-limits/limits.sh"
+)
+
+// fakeMount reports configfs mounted in the host root.
+const fakeMount = `#!/bin/bash
+echo "configfs on $HOST_ROOT/sys/kernel/config type configfs (rw)"
+`
+
+// fakeModprobe loads a module in the host root, unless it's listed in
+// FAIL_MODULES.
+const fakeModprobe = `#!/bin/bash
+mod="${@: -1}"
+for fail in $FAIL_MODULES; do
+    [ "$fail" == "$mod" ] && exit 1
+done
+mkdir -p "$HOST_ROOT/sys/module/$mod"
+echo live > "$HOST_ROOT/sys/module/$mod/initstate"
+`
+
+func TestEnableLIO(t *testing.T) {
+	testcases := []struct {
+		name         string
+		loaded       []string
+		failModules  string
+		wantExitCode int
+		wantResult   string
+		wantConf     string
+	}{
+		{
+			name:       "modules loaded",
+			loaded:     []string{"configfs", "target_core_mod", "tcm_loop", "target_core_file", "uio", "target_core_user"},
+			wantResult: "output.LIO_USER_BACKSTORE=available\n",
+		},
+		{
+			name:       "modules loaded by modprobe",
+			loaded:     []string{"configfs", "target_core_mod"},
+			wantResult: "output.LIO_USER_BACKSTORE=available\n",
+			wantConf:   "tcm_loop\ntarget_core_file\nuio\ntarget_core_user\n",
+		},
+		{
+			name:        "optional module not loaded",
+			loaded:      []string{"configfs", "target_core_mod", "tcm_loop", "target_core_file", "uio"},
+			failModules: "target_core_user",
+			wantResult: "status=warning\nmessage=Couldn't enable target_core_user\nremediation=modprobe target_core_user\n" +
+				"output.LIO_USER_BACKSTORE=unavailable\n",
+			wantConf: "target_core_user\n",
+		},
+		{
+			name:         "required module not loaded",
+			loaded:       []string{"configfs", "target_core_mod"},
+			failModules:  "tcm_loop",
+			wantExitCode: 1,
+			wantResult:   "status=failed\nmessage=The kernel module tcm_loop couldn't load\nremediation=modprobe tcm_loop\n",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			hostRoot, err := ioutil.TempDir("", "init-lib-host")
+			if err != nil {
+				t.Fatalf("failed to create temp dir: %v", err)
+			}
+			defer os.RemoveAll(hostRoot)
+
+			binDir := filepath.Join(hostRoot, "bin")
+			writeFile(t, filepath.Join(binDir, "mount"), fakeMount)
+			writeFile(t, filepath.Join(binDir, "modprobe"), fakeModprobe)
+			for _, name := range []string{"mount", "modprobe"} {
+				if err := os.Chmod(filepath.Join(binDir, name), 0755); err != nil {
+					t.Fatalf("failed to make %s executable: %v", name, err)
+				}
+			}
+			for _, mod := range tc.loaded {
+				writeFile(t, filepath.Join(hostRoot, "sys/module", mod, "initstate"), "live\n")
+			}
+			if err := os.MkdirAll(filepath.Join(hostRoot, "sys/kernel/config/target/core"), 0755); err != nil {
+				t.Fatalf("failed to create configfs dir: %v", err)
+			}
+
+			run := runWithLib(t, enableLIOScript, true,
+				"PATH="+binDir+":"+os.Getenv("PATH"),
+				"HOST_ROOT="+hostRoot,
+				"FAIL_MODULES="+tc.failModules,
+			)
+			if run.exitCode != tc.wantExitCode {
+				t.Errorf("unexpected exit code:\n\t(WNT) %d\n\t(GOT) %d\n%s", tc.wantExitCode, run.exitCode, run.stderr)
+			}
+			if run.result != tc.wantResult {
+				t.Errorf("unexpected result file:\n\t(WNT) %q\n\t(GOT) %q", tc.wantResult, run.result)
+			}
+
+			conf, _ := ioutil.ReadFile(filepath.Join(hostRoot, "etc/modules-load.d/lio.conf"))
+			if string(conf) != tc.wantConf {
+				t.Errorf("unexpected modules config:\n\t(WNT) %q\n\t(GOT) %q", tc.wantConf, string(conf))
+			}
+
+			if tc.wantExitCode == 0 {
+				if _, err := os.Stat(filepath.Join(hostRoot, "sys/kernel/config/target/loopback")); err != nil {
+					t.Errorf("loopback dir not created: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestLimits(t *testing.T) {
+	testcases := []struct {
+		name         string
+		pidsMax      string
+		env          []string
+		wantExitCode int
+		wantStdout   string
+		wantResult   string
+	}{
+		{
+			name:       "no requirements",
+			pidsMax:    "4096",
+			wantStdout: "INFO: Effective max.pids limit: 4096\n",
+			wantResult: "fact.max_pids=4096\nmetric.max_pids=4096\n",
+		},
+		{
+			name:         "below minimum",
+			pidsMax:      "512",
+			env:          []string{"MINIMUM_MAX_PIDS_LIMIT=1024"},
+			wantExitCode: 1,
+			wantResult: "fact.max_pids=512\nmetric.max_pids=512\n" +
+				"status=failed\nmessage=Effective max.pids limit (512) less than MINIMUM_MAX_PIDS_LIMIT (1024)\n" +
+				"remediation=raise the pids limit of the container runtime or the kubelet\n",
+		},
+		{
+			name:    "below recommended",
+			pidsMax: "2048",
+			env:     []string{"RECOMMENDED_MAX_PIDS_LIMIT=4096"},
+			wantResult: "fact.max_pids=2048\nmetric.max_pids=2048\n" +
+				"status=warning\nmessage=Effective max.pids limit (2048) less than RECOMMENDED_MAX_PIDS_LIMIT (4096)\n" +
+				"remediation=raise the pids limit of the container runtime or the kubelet\n",
+		},
+		{
+			name:       "at least recommended",
+			pidsMax:    "4096",
+			env:        []string{"RECOMMENDED_MAX_PIDS_LIMIT=4096"},
+			wantStdout: "INFO: OK: Effective max.pids limit (4096) at least RECOMMENDED_MAX_PIDS_LIMIT (4096)\n",
+			wantResult: "fact.max_pids=4096\nmetric.max_pids=4096\n",
+		},
+		{
+			name:       "unknown limit",
+			pidsMax:    "max",
+			wantResult: "status=warning\nmessage=Unable to determine effective max.pids limit\n",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			hostRoot, err := ioutil.TempDir("", "init-lib-host")
+			if err != nil {
+				t.Fatalf("failed to create temp dir: %v", err)
+			}
+			defer os.RemoveAll(hostRoot)
+
+			writeFile(t, filepath.Join(hostRoot, "sys/fs/cgroup/pids/kubepods/pids.max"), tc.pidsMax+"\n")
+			procCgroup := filepath.Join(hostRoot, "cgroup")
+			writeFile(t, procCgroup, "12:pids:/kubepods\n")
+
+			env := append([]string{"INIT_CGROUP_ROOT=" + filepath.Join(hostRoot, "sys/fs/cgroup"), "INIT_PROC_CGROUP=" + procCgroup}, tc.env...)
+			run := runWithLib(t, limitsScript, true, env...)
+			if run.exitCode != tc.wantExitCode {
+				t.Errorf("unexpected exit code:\n\t(WNT) %d\n\t(GOT) %d\n%s", tc.wantExitCode, run.exitCode, run.stderr)
+			}
+			if run.stdout != tc.wantStdout {
+				t.Errorf("unexpected stdout:\n\t(WNT) %q\n\t(GOT) %q", tc.wantStdout, run.stdout)
+			}
+			if run.result != tc.wantResult {
+				t.Errorf("unexpected result file:\n\t(WNT) %q\n\t(GOT) %q", tc.wantResult, run.result)
+			}
+			if tc.wantExitCode != 0 && !strings.Contains(run.stderr, "ERROR") {
+				t.Errorf("no error logged: %q", run.stderr)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package script
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"sigs.k8s.io/yaml"
+)
+
+// ManifestFile is the name of the optional manifest file that describes the
+// scripts in the same directory.
+const ManifestFile = "manifest.yaml"
+
+// Manifest contains the execution settings of a script.
+type Manifest struct {
+	// HostNamespaces runs the script in the mount and PID namespaces of the
+	// host, allowing the script to use the host binaries, e.g. modprobe
+	// matching the host kernel.
+	HostNamespaces bool `json:"hostNamespaces,omitempty"`
+	// Cleanup is the shell command that undoes the changes of the script,
+	// run by the cleanup mode.
+	Cleanup string `json:"cleanup,omitempty"`
+	// Recheck re-runs the script periodically in watch mode.
+	Recheck bool `json:"recheck,omitempty"`
+	// Tags are the tags the script can be selected or excluded with, e.g.
+	// kernel or advisory.
+	Tags []string `json:"tags,omitempty"`
+}
+
+// LoadManifest reads the manifest file in a given directory. An empty
+// manifest is returned if the directory has no manifest file.
+func LoadManifest(dir string) (Manifest, error) {
+	var m Manifest
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, ManifestFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return m, nil
+		}
+		return m, err
+	}
+
+	if err := yaml.UnmarshalStrict(data, &m); err != nil {
+		return m, fmt.Errorf("invalid manifest %q: %v", filepath.Join(dir, ManifestFile), err)
+	}
+
+	return m, nil
+}
@@ -0,0 +1,74 @@
+package script
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadManifest(t *testing.T) {
+	testcases := []struct {
+		name         string
+		manifest     string
+		noManifest   bool
+		wantManifest Manifest
+		wantErr      bool
+	}{
+		{
+			name:       "no manifest",
+			noManifest: true,
+		},
+		{
+			name:     "empty manifest",
+			manifest: "",
+		},
+		{
+			name:         "host namespaces",
+			manifest:     "hostNamespaces: true\n",
+			wantManifest: Manifest{HostNamespaces: true},
+		},
+		{
+			name:         "tags",
+			manifest:     "tags: [kernel, lio]\n",
+			wantManifest: Manifest{Tags: []string{"kernel", "lio"}},
+		},
+		{
+			name:     "unknown field",
+			manifest: "hostNamespace: true\n",
+			wantErr:  true,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "init-manifest-test")
+			if err != nil {
+				t.Fatalf("failed to create directory: %v", err)
+			}
+			defer os.RemoveAll(dir)
+
+			if !tc.noManifest {
+				if err := ioutil.WriteFile(filepath.Join(dir, ManifestFile), []byte(tc.manifest), 0644); err != nil {
+					t.Fatalf("failed to write manifest: %v", err)
+				}
+			}
+
+			m, err := LoadManifest(dir)
+			if err != nil {
+				if !tc.wantErr {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if tc.wantErr {
+				t.Fatal("expected error, got none")
+			}
+
+			if !reflect.DeepEqual(m, tc.wantManifest) {
+				t.Errorf("unexpected manifest:\n\t(WNT) %+v\n\t(GOT) %+v", tc.wantManifest, m)
+			}
+		})
+	}
+}
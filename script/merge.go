@@ -0,0 +1,113 @@
+package script
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/storageos/init/info"
+)
+
+// docFileExt are the extensions of the documentation files that are not
+// executable.
+var docFileExt = map[string]bool{
+	".md":  true,
+	".txt": true,
+}
+
+// Materialize writes script sets into a directory, a subdirectory per set
+// named after the set, and returns the scripts in the directory. Script files
+// are written executable, documentation, manifest, checksums and signature
+// files are not. The source of each script is the source of its set. If a
+// verifier is given, each set directory is verified after it's written.
+func Materialize(dir string, sets []info.ScriptSet, verifier *Verifier) ([]Script, error) {
+	sources := map[string]string{}
+
+	for _, set := range sets {
+		if set.Name == "" || set.Name != filepath.Base(set.Name) || set.Name == "." || set.Name == ".." {
+			return nil, fmt.Errorf("invalid script set name %q from %s", set.Name, set.Source)
+		}
+		setDir := filepath.Join(dir, set.Name)
+		if err := os.MkdirAll(setDir, 0755); err != nil {
+			return nil, err
+		}
+
+		for name, content := range set.Files {
+			if name != filepath.Base(name) || name == "." || name == ".." {
+				return nil, fmt.Errorf("invalid script file name %q in %s", name, set.Source)
+			}
+
+			mode := os.FileMode(0755)
+			if docFileExt[filepath.Ext(name)] || name == ManifestFile || name == ChecksumsFile || name == SignatureFile {
+				mode = 0644
+			}
+
+			path := filepath.Join(setDir, name)
+			if err := ioutil.WriteFile(path, content, mode); err != nil {
+				return nil, err
+			}
+			// Ensure the mode regardless of the umask.
+			if err := os.Chmod(path, mode); err != nil {
+				return nil, err
+			}
+		}
+
+		if verifier != nil {
+			if err := verifier.Verify(setDir); err != nil {
+				return nil, fmt.Errorf("%s: %v", set.Source, err)
+			}
+		}
+
+		sources[set.Name] = set.Source
+	}
+
+	scripts, err := GetAllScripts(dir)
+	if err != nil {
+		return nil, err
+	}
+	for i := range scripts {
+		scripts[i].Source = sources[scripts[i].Name]
+	}
+
+	return scripts, nil
+}
+
+// Merge merges two lists of scripts. The scripts in extra replace all the
+// scripts in base with the same name. The merged list is sorted by the script
+// relative paths, the same order as the scripts in a single scripts directory.
+func Merge(base, extra []Script) []Script {
+	replaced := map[string]bool{}
+	for _, s := range extra {
+		replaced[s.Name] = true
+	}
+
+	merged := []Script{}
+	for _, s := range base {
+		if !replaced[s.Name] {
+			merged = append(merged, s)
+		}
+	}
+	merged = append(merged, extra...)
+
+	sort.SliceStable(merged, func(a, b int) bool {
+		return lessPath(merged[a].RelPath, merged[b].RelPath)
+	})
+
+	return merged
+}
+
+// lessPath compares two relative paths element by element, the order in which
+// filepath.Walk visits the files.
+func lessPath(a, b string) bool {
+	ae := strings.Split(filepath.ToSlash(a), "/")
+	be := strings.Split(filepath.ToSlash(b), "/")
+	for i := 0; i < len(ae) && i < len(be); i++ {
+		if ae[i] != be[i] {
+			return ae[i] < be[i]
+		}
+	}
+	return len(ae) < len(be)
+}
@@ -0,0 +1,133 @@
+package script
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/storageos/init/info"
+)
+
+func TestMaterialize(t *testing.T) {
+	dir, err := ioutil.TempDir("", "init-materialize-test")
+	if err != nil {
+		t.Fatalf("failed to create directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	sets := []info.ScriptSet{
+		{
+			Name:   "05-site",
+			Source: "configmap kube-system/05-site",
+			Files: map[string][]byte{
+				"check.sh":   []byte("#!/bin/bash\n"),
+				"README.md":  []byte("docs"),
+				ManifestFile: []byte("hostNamespaces: true\n"),
+			},
+		},
+	}
+
+	scripts, err := Materialize(dir, sets, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(scripts) != 1 {
+		t.Fatalf("unexpected number of scripts:\n\t(WNT) %d\n\t(GOT) %d", 1, len(scripts))
+	}
+	s := scripts[0]
+	if s.Name != "05-site" || s.Source != "configmap kube-system/05-site" || !s.Manifest.HostNamespaces {
+		t.Errorf("unexpected script: %+v", s)
+	}
+
+	// Check the file modes.
+	modes := map[string]os.FileMode{
+		"check.sh":   0755,
+		"README.md":  0644,
+		ManifestFile: 0644,
+	}
+	for name, want := range modes {
+		fi, err := os.Stat(filepath.Join(dir, "05-site", name))
+		if err != nil {
+			t.Fatalf("failed to stat %s: %v", name, err)
+		}
+		if fi.Mode().Perm() != want {
+			t.Errorf("unexpected mode of %s:\n\t(WNT) %v\n\t(GOT) %v", name, want, fi.Mode().Perm())
+		}
+	}
+
+	// Invalid names must be rejected.
+	for _, set := range []info.ScriptSet{
+		{Name: "../foo"},
+		{Name: "foo", Files: map[string][]byte{"../bar.sh": nil}},
+	} {
+		if _, err := Materialize(dir, []info.ScriptSet{set}, nil); err == nil {
+			t.Errorf("expected error for script set %+v", set)
+		}
+	}
+
+	// A verified set must carry matching checksums.
+	set := info.ScriptSet{
+		Name:   "06-signed",
+		Source: "configmap kube-system/06-signed",
+		Files: map[string][]byte{
+			"check.sh":    []byte("#!/bin/bash\n"),
+			ChecksumsFile: []byte(checksumLine("check.sh", "#!/bin/bash\n")),
+		},
+	}
+	scripts, err = Materialize(dir, []info.ScriptSet{set}, NewVerifier())
+	if err != nil {
+		t.Fatalf("unexpected verification error: %v", err)
+	}
+	if len(scripts) != 2 {
+		t.Errorf("unexpected number of scripts:\n\t(WNT) %d\n\t(GOT) %d", 2, len(scripts))
+	}
+	set.Files["check.sh"] = []byte("#!/bin/bash\nrm -rf /\n")
+	if _, err := Materialize(dir, []info.ScriptSet{set}, NewVerifier()); err == nil {
+		t.Error("expected verification error for modified script")
+	}
+}
+
+func TestMerge(t *testing.T) {
+	base := []Script{
+		{Name: "01-lio", RelPath: "01-lio/enable-lio.sh", Source: "/scripts"},
+		{Name: "02-limits", RelPath: "02-limits/limits.sh", Source: "/scripts"},
+		{Name: "05-foo", RelPath: "05-foo.sh", Source: "/scripts"},
+	}
+	extra := []Script{
+		{Name: "02-limits", RelPath: "02-limits/site-limits.sh", Source: "configmap"},
+		{Name: "05-foo", RelPath: "05-foo/foo.sh", Source: "configmap"},
+		{Name: "03-site", RelPath: "03-site/check.sh", Source: "configmap"},
+	}
+
+	want := []Script{
+		{Name: "01-lio", RelPath: "01-lio/enable-lio.sh", Source: "/scripts"},
+		{Name: "02-limits", RelPath: "02-limits/site-limits.sh", Source: "configmap"},
+		{Name: "03-site", RelPath: "03-site/check.sh", Source: "configmap"},
+		{Name: "05-foo", RelPath: "05-foo/foo.sh", Source: "configmap"},
+	}
+
+	if got := Merge(base, extra); !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected merged scripts:\n\t(WNT) %v\n\t(GOT) %v", want, got)
+	}
+}
+
+func TestLessPath(t *testing.T) {
+	testcases := []struct {
+		a, b string
+		want bool
+	}{
+		{a: "01-script.sh", b: "05-foo/scriptx.sh", want: true},
+		{a: "05-foo/scriptx.sh", b: "05-foo.sh", want: true},
+		{a: "05-foo.sh", b: "05-foo/scriptx.sh", want: false},
+		{a: "foo/a.sh", b: "foo/b.sh", want: true},
+	}
+
+	for _, tc := range testcases {
+		if got := lessPath(tc.a, tc.b); got != tc.want {
+			t.Errorf("unexpected lessPath(%q, %q):\n\t(WNT) %t\n\t(GOT) %t", tc.a, tc.b, tc.want, got)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package script
+
+import (
+	"io/ioutil"
+	"strings"
+)
+
+// TombstoneExt is the extension of the tombstone files that disable a script
+// of a lower scripts directory, e.g. 02-limits.disabled.
+const TombstoneExt = ".disabled"
+
+// GetOverlayScripts returns the scripts of a list of scripts directories
+// overlaid in order. A later directory can add scripts, replace all the
+// scripts with the same name in the lower directories, or disable them with a
+// tombstone file named after the script at its top level. If a verifier is
+// given, each directory is verified before its scripts are listed.
+func GetOverlayScripts(scriptsDirs []string, verifier *Verifier) ([]Script, error) {
+	scripts := []Script{}
+
+	for _, dir := range scriptsDirs {
+		if verifier != nil {
+			if err := verifier.Verify(dir); err != nil {
+				return nil, err
+			}
+		}
+
+		dirScripts, err := GetAllScripts(dir)
+		if err != nil {
+			return nil, err
+		}
+		scripts = Merge(scripts, dirScripts)
+
+		tombstones, err := getTombstones(dir)
+		if err != nil {
+			return nil, err
+		}
+		if len(tombstones) == 0 {
+			continue
+		}
+
+		enabled := []Script{}
+		for _, s := range scripts {
+			if !tombstones[s.Name] {
+				enabled = append(enabled, s)
+			}
+		}
+		scripts = enabled
+	}
+
+	return scripts, nil
+}
+
+// getTombstones returns the names of the scripts disabled by the tombstone
+// files at the top level of a scripts directory.
+func getTombstones(dir string) (map[string]bool, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	tombstones := map[string]bool{}
+	for _, f := range files {
+		if !f.IsDir() && strings.HasSuffix(f.Name(), TombstoneExt) {
+			tombstones[strings.TrimSuffix(f.Name(), TombstoneExt)] = true
+		}
+	}
+	return tombstones, nil
+}
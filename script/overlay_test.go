@@ -0,0 +1,73 @@
+package script
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGetOverlayScripts(t *testing.T) {
+	// Files of each scripts directory, in overlay order.
+	dirFiles := [][]string{
+		{
+			"01-lio/enable-lio.sh",
+			"02-limits/limits.sh",
+			"03-foo.sh",
+		},
+		{
+			"02-limits/site-limits.sh",
+			"03-foo.disabled",
+			"04-site/check.sh",
+		},
+		{
+			"01-lio/enable-lio.sh",
+		},
+	}
+
+	wantScripts := []struct {
+		relPath string
+		dir     int
+	}{
+		{relPath: "01-lio/enable-lio.sh", dir: 2},
+		{relPath: "02-limits/site-limits.sh", dir: 1},
+		{relPath: "04-site/check.sh", dir: 1},
+	}
+
+	dirs := []string{}
+	for _, files := range dirFiles {
+		dir, err := ioutil.TempDir("", "init-overlay-test")
+		if err != nil {
+			t.Fatalf("failed to create directory: %v", err)
+		}
+		defer os.RemoveAll(dir)
+
+		for _, file := range files {
+			path := filepath.Join(dir, file)
+			if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+				t.Fatalf("failed to create sub directory: %v", err)
+			}
+			if err := ioutil.WriteFile(path, nil, 0755); err != nil {
+				t.Fatalf("failed to create script file: %v", err)
+			}
+		}
+		dirs = append(dirs, dir)
+	}
+
+	scripts, err := GetOverlayScripts(dirs, nil)
+	if err != nil {
+		t.Fatalf("failed to get overlay scripts: %v", err)
+	}
+
+	if len(scripts) != len(wantScripts) {
+		t.Fatalf("unexpected number of scripts:\n\t(WNT) %d\n\t(GOT) %d", len(wantScripts), len(scripts))
+	}
+	for i, want := range wantScripts {
+		if scripts[i].RelPath != filepath.FromSlash(want.relPath) {
+			t.Errorf("unexpected script at position %d:\n\t(WNT) %s\n\t(GOT) %s", i, want.relPath, scripts[i].RelPath)
+		}
+		if scripts[i].Source != dirs[want.dir] {
+			t.Errorf("unexpected source of %s:\n\t(WNT) %s\n\t(GOT) %s", want.relPath, dirs[want.dir], scripts[i].Source)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package script
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ResultFileEnvVar is the env var that contains the path of the file a script
+// can write its structured result to.
+const ResultFileEnvVar = "INIT_RESULT_FILE"
+
+// Record statuses a script can report.
+const (
+	RecordPassed  = "passed"
+	RecordWarning = "warning"
+	RecordFailed  = "failed"
+	RecordSkipped = "skipped"
+)
+
+// Prefixes of the fact, metric and output keys in the key=value format.
+const (
+	factKeyPrefix   = "fact."
+	metricKeyPrefix = "metric."
+	outputKeyPrefix = "output."
+)
+
+// OutputEnvVarPrefix is the prefix of the env vars passed to the scripts with
+// the outputs of the scripts that ran before them.
+const OutputEnvVarPrefix = "INIT_OUTPUT_"
+
+// outputName matches the valid output names, usable in env var names.
+var outputName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// nonEnvVarChars matches the characters of a script name not allowed in env
+// var names.
+var nonEnvVarChars = regexp.MustCompile(`[^A-Z0-9_]`)
+
+// OutputEnvVar returns the name of the env var of an output published by a
+// script, namespaced by the script name, e.g. INIT_OUTPUT_01_LIO_FOO for the
+// output FOO of the 01-lio script.
+func OutputEnvVar(scriptName, output string) string {
+	return OutputEnvVarPrefix + nonEnvVarChars.ReplaceAllString(strings.ToUpper(scriptName), "_") + "_" + output
+}
+
+// Record is the structured result a script writes to its result file.
+type Record struct {
+	// Status overrides the status derived from the exit code and stderr of
+	// a script that exited successfully: passed, warning, failed or skipped.
+	Status string `json:"status,omitempty"`
+	// Message is a human readable summary of the result.
+	Message string `json:"message,omitempty"`
+	// Remediation is a hint to fix a failure or warning.
+	Remediation string `json:"remediation,omitempty"`
+	// Facts are the facts discovered about the host, e.g. the kernel
+	// modules available.
+	Facts map[string]string `json:"facts,omitempty"`
+	// Metrics are the numeric values measured by the script.
+	Metrics map[string]float64 `json:"metrics,omitempty"`
+	// Outputs are the named values published by the script to the scripts
+	// that run after it.
+	Outputs map[string]string `json:"outputs,omitempty"`
+}
+
+// ParseRecord parses the content of a result file. The content is either JSON
+// objects, e.g. one per line, or key=value lines with the status, message,
+// remediation, fact.<name>, metric.<name> and output.<name> keys, ignoring
+// empty lines and lines starting with #. Successive records are merged, the
+// last value of each key wins. It returns nil if the content is empty.
+func ParseRecord(data []byte) (*Record, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	r := &Record{}
+	var err error
+	if data[0] == '{' {
+		err = r.parseJSON(data)
+	} else {
+		err = r.parseKeyValue(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := r.Validate(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Validate returns an error if the status or an output name of the record is
+// invalid.
+func (r *Record) Validate() error {
+	switch r.Status {
+	case "", RecordPassed, RecordWarning, RecordFailed, RecordSkipped:
+	default:
+		return fmt.Errorf("invalid status %q", r.Status)
+	}
+	for name := range r.Outputs {
+		if !outputName.MatchString(name) {
+			return fmt.Errorf("invalid output name %q", name)
+		}
+	}
+	return nil
+}
+
+// parseJSON merges a stream of JSON records into the record.
+func (r *Record) parseJSON(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	for {
+		var next Record
+		if err := dec.Decode(&next); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		r.Merge(next)
+	}
+}
+
+// parseKeyValue merges key=value lines into the record.
+func (r *Record) parseKeyValue(data []byte) error {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		parts := strings.SplitN(text, "=", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("line %d: expected key=value", line)
+		}
+		key, value := strings.TrimSpace(parts[0]), parts[1]
+
+		var next Record
+		switch {
+		case key == "status":
+			next.Status = value
+		case key == "message":
+			next.Message = value
+		case key == "remediation":
+			next.Remediation = value
+		case strings.HasPrefix(key, factKeyPrefix) && len(key) > len(factKeyPrefix):
+			next.Facts = map[string]string{strings.TrimPrefix(key, factKeyPrefix): value}
+		case strings.HasPrefix(key, metricKeyPrefix) && len(key) > len(metricKeyPrefix):
+			v, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil {
+				return fmt.Errorf("line %d: invalid metric value %q", line, value)
+			}
+			next.Metrics = map[string]float64{strings.TrimPrefix(key, metricKeyPrefix): v}
+		case strings.HasPrefix(key, outputKeyPrefix) && len(key) > len(outputKeyPrefix):
+			next.Outputs = map[string]string{strings.TrimPrefix(key, outputKeyPrefix): value}
+		default:
+			return fmt.Errorf("line %d: unknown key %q", line, key)
+		}
+		r.Merge(next)
+	}
+	return scanner.Err()
+}
+
+// Merge merges another record into the record, the values of the other record
+// win.
+func (r *Record) Merge(other Record) {
+	if other.Status != "" {
+		r.Status = other.Status
+	}
+	if other.Message != "" {
+		r.Message = other.Message
+	}
+	if other.Remediation != "" {
+		r.Remediation = other.Remediation
+	}
+	for k, v := range other.Facts {
+		if r.Facts == nil {
+			r.Facts = map[string]string{}
+		}
+		r.Facts[k] = v
+	}
+	for k, v := range other.Metrics {
+		if r.Metrics == nil {
+			r.Metrics = map[string]float64{}
+		}
+		r.Metrics[k] = v
+	}
+	for k, v := range other.Outputs {
+		if r.Outputs == nil {
+			r.Outputs = map[string]string{}
+		}
+		r.Outputs[k] = v
+	}
+}
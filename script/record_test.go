@@ -0,0 +1,135 @@
+package script
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseRecord(t *testing.T) {
+	testcases := []struct {
+		name       string
+		data       string
+		wantRecord *Record
+		wantErr    bool
+	}{
+		{
+			name: "empty",
+			data: " \n",
+		},
+		{
+			name: "json",
+			data: `{"status": "warning", "message": "uio missing", "remediation": "modprobe uio", "facts": {"kernel": "5.4"}, "metrics": {"modules": 4}}`,
+			wantRecord: &Record{
+				Status:      RecordWarning,
+				Message:     "uio missing",
+				Remediation: "modprobe uio",
+				Facts:       map[string]string{"kernel": "5.4"},
+				Metrics:     map[string]float64{"modules": 4},
+			},
+		},
+		{
+			name: "json lines",
+			data: `{"facts": {"kernel": "5.4"}}
+{"facts": {"cgroup": "v2"}, "status": "passed"}
+{"message": "done"}
+`,
+			wantRecord: &Record{
+				Status:  RecordPassed,
+				Message: "done",
+				Facts:   map[string]string{"kernel": "5.4", "cgroup": "v2"},
+			},
+		},
+		{
+			name: "key value",
+			data: `# Result of the check.
+status=failed
+message=max pids = 512
+
+remediation=raise the pids limit
+fact.max_pids=512
+metric.max_pids=512
+metric.ratio=0.5
+output.MAX_PIDS=512
+`,
+			wantRecord: &Record{
+				Status:      RecordFailed,
+				Message:     "max pids = 512",
+				Remediation: "raise the pids limit",
+				Facts:       map[string]string{"max_pids": "512"},
+				Metrics:     map[string]float64{"max_pids": 512, "ratio": 0.5},
+				Outputs:     map[string]string{"MAX_PIDS": "512"},
+			},
+		},
+		{
+			name: "json outputs",
+			data: `{"outputs": {"LIO_USER_BACKSTORE": "available"}}`,
+			wantRecord: &Record{
+				Outputs: map[string]string{"LIO_USER_BACKSTORE": "available"},
+			},
+		},
+		{
+			name:    "unknown json field",
+			data:    `{"state": "passed"}`,
+			wantErr: true,
+		},
+		{
+			name:    "unknown key",
+			data:    "state=passed\n",
+			wantErr: true,
+		},
+		{
+			name:    "invalid metric",
+			data:    "metric.foo=bar\n",
+			wantErr: true,
+		},
+		{
+			name:    "invalid output name",
+			data:    "output.foo-bar=baz\n",
+			wantErr: true,
+		},
+		{
+			name:    "invalid status",
+			data:    "status=great\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := ParseRecord([]byte(tc.data))
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(r, tc.wantRecord) {
+				t.Errorf("unexpected record:\n\t(WNT) %+v\n\t(GOT) %+v", tc.wantRecord, r)
+			}
+		})
+	}
+}
+
+func TestOutputEnvVar(t *testing.T) {
+	testcases := []struct {
+		scriptName string
+		output     string
+		want       string
+	}{
+		{
+			scriptName: "01-lio",
+			output:     "LIO_USER_BACKSTORE",
+			want:       "INIT_OUTPUT_01_LIO_LIO_USER_BACKSTORE",
+		},
+		{
+			scriptName: "check.limits",
+			output:     "max_pids",
+			want:       "INIT_OUTPUT_CHECK_LIMITS_max_pids",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.scriptName, func(t *testing.T) {
+			if got := OutputEnvVar(tc.scriptName, tc.output); got != tc.want {
+				t.Errorf("unexpected env var:\n\t(WNT) %s\n\t(GOT) %s", tc.want, got)
+			}
+		})
+	}
+}
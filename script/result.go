@@ -0,0 +1,119 @@
+package script
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+	"time"
+)
+
+// Env vars that contain the paths of the directories of a script run.
+const (
+	// WorkDirEnvVar is the env var that contains the path of the private
+	// working directory of a script, removed after the script runs.
+	WorkDirEnvVar = "INIT_WORKDIR"
+	// ArtifactsEnvVar is the env var that contains the path of the directory
+	// a script can write files to, preserved after the script runs.
+	ArtifactsEnvVar = "INIT_ARTIFACTS"
+)
+
+// ErrInterrupted is returned by a Runner when a script is not started because
+// the runner was interrupted by a termination signal.
+var ErrInterrupted = errors.New("interrupted before start")
+
+// signalNames maps the common terminating signals to their names.
+var signalNames = map[syscall.Signal]string{
+	syscall.SIGABRT: "SIGABRT",
+	syscall.SIGBUS:  "SIGBUS",
+	syscall.SIGFPE:  "SIGFPE",
+	syscall.SIGHUP:  "SIGHUP",
+	syscall.SIGILL:  "SIGILL",
+	syscall.SIGINT:  "SIGINT",
+	syscall.SIGKILL: "SIGKILL",
+	syscall.SIGPIPE: "SIGPIPE",
+	syscall.SIGQUIT: "SIGQUIT",
+	syscall.SIGSEGV: "SIGSEGV",
+	syscall.SIGTERM: "SIGTERM",
+	syscall.SIGUSR1: "SIGUSR1",
+	syscall.SIGUSR2: "SIGUSR2",
+}
+
+// SignalName returns the name of a signal, e.g. SIGKILL.
+func SignalName(sig syscall.Signal) string {
+	if name, ok := signalNames[sig]; ok {
+		return name
+	}
+	return fmt.Sprintf("signal %d", int(sig))
+}
+
+// Result is the result of a script execution.
+type Result struct {
+	// Stdout and Stderr are the captured output of the script, truncated to
+	// the runner capture limits.
+	Stdout []byte
+	Stderr []byte
+	// StdoutTruncated and StderrTruncated are the number of bytes discarded
+	// from the captured output.
+	StdoutTruncated int64
+	StderrTruncated int64
+
+	// ExitCode is the exit code of the script, or -1 if it was terminated by
+	// a signal.
+	ExitCode int
+	// Signal is the signal that terminated the script, if any.
+	Signal syscall.Signal
+	// CoreDumped is set when the script terminated with a core dump.
+	CoreDumped bool
+	// Interrupted is set when a termination signal was forwarded to the
+	// script.
+	Interrupted bool
+
+	// WallTime is the elapsed real time of the execution.
+	WallTime time.Duration
+	// UserTime and SystemTime are the user and system CPU time of the script
+	// and its waited-for children.
+	UserTime   time.Duration
+	SystemTime time.Duration
+	// MaxRSS is the maximum resident set size in bytes.
+	MaxRSS int64
+
+	// Record is the structured result written by the script to its result
+	// file, or nil if nothing was written.
+	Record *Record
+	// Artifacts are the paths of the files the script preserved in its
+	// artifacts directory.
+	Artifacts []string
+}
+
+// Success returns true if the script exited with zero exit code and was not
+// interrupted.
+func (r *Result) Success() bool {
+	return r.Signal == 0 && r.ExitCode == 0 && !r.Interrupted
+}
+
+// String returns a human readable description of how the script terminated,
+// e.g. "exited 3", "killed by SIGKILL (OOM?)" or "interrupted, killed by
+// SIGTERM".
+func (r *Result) String() string {
+	if r.Interrupted {
+		return "interrupted, " + r.describeExit()
+	}
+	return r.describeExit()
+}
+
+// describeExit describes the exit status of the script.
+func (r *Result) describeExit() string {
+	if r.Signal == 0 {
+		return fmt.Sprintf("exited %d", r.ExitCode)
+	}
+
+	desc := fmt.Sprintf("killed by %s", SignalName(r.Signal))
+	if r.Signal == syscall.SIGKILL && !r.Interrupted {
+		// SIGKILL is most likely sent by the kernel OOM killer.
+		desc += " (OOM?)"
+	}
+	if r.CoreDumped {
+		desc += " (core dumped)"
+	}
+	return desc
+}
@@ -0,0 +1,63 @@
+package script
+
+import (
+	"syscall"
+	"testing"
+)
+
+func TestResultString(t *testing.T) {
+	testcases := []struct {
+		name        string
+		result      Result
+		wantString  string
+		wantSuccess bool
+	}{
+		{
+			name:        "success",
+			result:      Result{ExitCode: 0},
+			wantString:  "exited 0",
+			wantSuccess: true,
+		},
+		{
+			name:       "non-zero exit code",
+			result:     Result{ExitCode: 3},
+			wantString: "exited 3",
+		},
+		{
+			name:       "killed by SIGKILL",
+			result:     Result{ExitCode: -1, Signal: syscall.SIGKILL},
+			wantString: "killed by SIGKILL (OOM?)",
+		},
+		{
+			name:       "core dumped",
+			result:     Result{ExitCode: -1, Signal: syscall.SIGSEGV, CoreDumped: true},
+			wantString: "killed by SIGSEGV (core dumped)",
+		},
+		{
+			name:       "unknown signal",
+			result:     Result{ExitCode: -1, Signal: syscall.Signal(40)},
+			wantString: "killed by signal 40",
+		},
+		{
+			name:       "interrupted",
+			result:     Result{ExitCode: -1, Signal: syscall.SIGTERM, Interrupted: true},
+			wantString: "interrupted, killed by SIGTERM",
+		},
+		{
+			name:       "interrupted and killed",
+			result:     Result{ExitCode: -1, Signal: syscall.SIGKILL, Interrupted: true},
+			wantString: "interrupted, killed by SIGKILL",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.result.String(); got != tc.wantString {
+				t.Errorf("unexpected result string:\n\t(WNT) %s\n\t(GOT) %s", tc.wantString, got)
+			}
+			if got := tc.result.Success(); got != tc.wantSuccess {
+				t.Errorf("unexpected result success:\n\t(WNT) %t\n\t(GOT) %t", tc.wantSuccess, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package runner
+
+import (
+	"fmt"
+)
+
+const (
+	// DefaultCaptureHead is the default number of bytes retained from the
+	// start of a script output stream.
+	DefaultCaptureHead = 32 * 1024
+	// DefaultCaptureTail is the default number of bytes retained from the end
+	// of a script output stream.
+	DefaultCaptureTail = 32 * 1024
+)
+
+// truncationMarker is inserted between the retained head and tail of a
+// truncated output stream.
+const truncationMarker = "\n[... %d bytes truncated ...]\n"
+
+// captureBuffer is an io.Writer that retains the first headLimit and the last
+// tailLimit bytes written to it and discards everything in between. This
+// bounds the memory used to capture the output of a chatty script.
+type captureBuffer struct {
+	headLimit int
+	tailLimit int
+
+	head  []byte
+	tail  []byte
+	total int64
+}
+
+// newCaptureBuffer returns a captureBuffer with the given head and tail
+// limits in bytes.
+func newCaptureBuffer(headLimit, tailLimit int) *captureBuffer {
+	return &captureBuffer{
+		headLimit: headLimit,
+		tailLimit: tailLimit,
+	}
+}
+
+// Write implements io.Writer. It never returns an error so that the other
+// writers of a multiwriter are not interrupted.
+func (b *captureBuffer) Write(p []byte) (int, error) {
+	n := len(p)
+	b.total += int64(n)
+
+	// Fill the head first.
+	if room := b.headLimit - len(b.head); room > 0 {
+		if room > len(p) {
+			room = len(p)
+		}
+		b.head = append(b.head, p[:room]...)
+		p = p[room:]
+	}
+
+	if b.tailLimit <= 0 || len(p) == 0 {
+		return n, nil
+	}
+
+	// Keep only the last tailLimit bytes.
+	if len(p) >= b.tailLimit {
+		b.tail = append(b.tail[:0], p[len(p)-b.tailLimit:]...)
+		return n, nil
+	}
+	b.tail = append(b.tail, p...)
+	if over := len(b.tail) - b.tailLimit; over > 0 {
+		copy(b.tail, b.tail[over:])
+		b.tail = b.tail[:b.tailLimit]
+	}
+
+	return n, nil
+}
+
+// Truncated returns the number of bytes that were discarded.
+func (b *captureBuffer) Truncated() int64 {
+	return b.total - int64(len(b.head)) - int64(len(b.tail))
+}
+
+// Bytes returns the retained data. If any data was discarded, a truncation
+// marker is inserted between the head and the tail.
+func (b *captureBuffer) Bytes() []byte {
+	truncated := b.Truncated()
+	if truncated == 0 {
+		return append(append([]byte{}, b.head...), b.tail...)
+	}
+
+	out := append([]byte{}, b.head...)
+	out = append(out, fmt.Sprintf(truncationMarker, truncated)...)
+	return append(out, b.tail...)
+}
@@ -0,0 +1,83 @@
+package runner
+
+import (
+	"testing"
+)
+
+func TestCaptureBuffer(t *testing.T) {
+	testcases := []struct {
+		name          string
+		headLimit     int
+		tailLimit     int
+		writes        []string
+		wantOut       string
+		wantTruncated int64
+	}{
+		{
+			name:      "within limits",
+			headLimit: 4,
+			tailLimit: 4,
+			writes:    []string{"abc", "def"},
+			wantOut:   "abcdef",
+		},
+		{
+			name:          "truncated in a single write",
+			headLimit:     3,
+			tailLimit:     3,
+			writes:        []string{"abcdefghij"},
+			wantOut:       "abc\n[... 4 bytes truncated ...]\nhij",
+			wantTruncated: 4,
+		},
+		{
+			name:          "truncated across writes",
+			headLimit:     2,
+			tailLimit:     3,
+			writes:        []string{"a", "bc", "de", "f", "ghi", "j"},
+			wantOut:       "ab\n[... 5 bytes truncated ...]\nhij",
+			wantTruncated: 5,
+		},
+		{
+			name:          "head only",
+			headLimit:     2,
+			tailLimit:     0,
+			writes:        []string{"abcd"},
+			wantOut:       "ab\n[... 2 bytes truncated ...]\n",
+			wantTruncated: 2,
+		},
+		{
+			name:          "tail only",
+			headLimit:     0,
+			tailLimit:     2,
+			writes:        []string{"abcd"},
+			wantOut:       "\n[... 2 bytes truncated ...]\ncd",
+			wantTruncated: 2,
+		},
+		{
+			name:      "no data",
+			headLimit: 2,
+			tailLimit: 2,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			b := newCaptureBuffer(tc.headLimit, tc.tailLimit)
+			for _, data := range tc.writes {
+				n, err := b.Write([]byte(data))
+				if err != nil {
+					t.Fatalf("unexpected write error: %v", err)
+				}
+				if n != len(data) {
+					t.Errorf("unexpected number of bytes written:\n\t(WNT) %d\n\t(GOT) %d", len(data), n)
+				}
+			}
+
+			if string(b.Bytes()) != tc.wantOut {
+				t.Errorf("unexpected captured data:\n\t(WNT) %q\n\t(GOT) %q", tc.wantOut, string(b.Bytes()))
+			}
+			if b.Truncated() != tc.wantTruncated {
+				t.Errorf("unexpected truncated bytes:\n\t(WNT) %d\n\t(GOT) %d", tc.wantTruncated, b.Truncated())
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package runner
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/storageos/init/host"
+	scriptpkg "github.com/storageos/init/script"
+	"github.com/storageos/init/script/star"
+)
+
+// runCheck evaluates a Starlark check in process, streaming and capturing its
+// output like a script. The check inspects the host root from the HOST_ROOT
+// env var, or the root of the namespace target process if the check requires
+// the host namespaces. An evaluation error is returned with a Result with the
+// exit code 1.
+func (r *Run) runCheck(s scriptpkg.Script, env map[string]string, arg ...string) (*scriptpkg.Result, error) {
+	root := host.NewRoot(env[host.EnvVar])
+	if s.Manifest.HostNamespaces {
+		root = host.NewRoot(fmt.Sprintf("/proc/%d/root", r.nsTarget))
+	}
+
+	// The check reads the same env vars as a script.
+	checkEnv := map[string]string{}
+	for _, kv := range os.Environ() {
+		if parts := strings.SplitN(kv, "=", 2); len(parts) == 2 {
+			checkEnv[parts[0]] = parts[1]
+		}
+	}
+	for k, v := range env {
+		checkEnv[k] = v
+	}
+
+	stdoutBuf := newCaptureBuffer(r.captureHead, r.captureTail)
+	stderrBuf := newCaptureBuffer(r.captureHead, r.captureTail)
+	stdoutLines := r.newLineWriter(r.stdout, s.Name, streamStdout)
+	stderrLines := r.newLineWriter(r.stderr, s.Name, streamStderr)
+
+	check := star.NewCheck(s.Path, root).
+		SetEnv(checkEnv).
+		SetArgs(arg...).
+		SetOutput(io.MultiWriter(stdoutLines, stdoutBuf), io.MultiWriter(stderrLines, stderrBuf))
+
+	// Track the check, cancelling it if the runner was interrupted while it
+	// was starting.
+	r.mu.Lock()
+	r.check = check
+	if r.interrupt != 0 {
+		check.Cancel(interruptReason(r.interrupt))
+	}
+	r.mu.Unlock()
+
+	start := time.Now()
+	record, err := check.Run()
+	wallTime := time.Since(start)
+
+	r.mu.Lock()
+	r.check = nil
+	interrupted := r.interrupt != 0
+	r.mu.Unlock()
+
+	stdoutLines.Flush()
+	stderrLines.Flush()
+
+	result := &scriptpkg.Result{
+		Stdout:          stdoutBuf.Bytes(),
+		Stderr:          stderrBuf.Bytes(),
+		StdoutTruncated: stdoutBuf.Truncated(),
+		StderrTruncated: stderrBuf.Truncated(),
+		Interrupted:     interrupted,
+		WallTime:        wallTime,
+		Record:          record,
+	}
+	if err != nil {
+		result.ExitCode = 1
+		return result, err
+	}
+	return result, nil
+}
+
+// interruptReason returns the reason a check is cancelled with when the runner
+// is interrupted by a signal.
+func interruptReason(sig syscall.Signal) string {
+	return "interrupted by " + scriptpkg.SignalName(sig)
+}
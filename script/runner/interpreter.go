@@ -0,0 +1,93 @@
+package runner
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	scriptpkg "github.com/storageos/init/script"
+	"github.com/storageos/init/script/star"
+)
+
+// DefaultInterpreters maps the script file extensions to the interpreters of
+// the script files that are not executable or don't start with a shebang.
+var DefaultInterpreters = map[string]string{
+	".sh": "/bin/bash",
+	".py": "python3",
+}
+
+// SetInterpreters sets the interpreters of the script files that are not
+// executable or don't start with a shebang, by file extension, e.g. ".sh".
+func (r *Run) SetInterpreters(interpreters map[string]string) *Run {
+	r.interpreters = interpreters
+	return r
+}
+
+// interpreter returns the interpreter a script file is run with, or "" if the
+// file is executed directly. A file that is executable and starts with a
+// shebang, or that is executable without an interpreter for its extension,
+// e.g. a binary, is executed directly. A file that can't be read is left to
+// fail at execution.
+func (r *Run) interpreter(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", nil
+	}
+	executable := info.Mode()&0111 != 0
+
+	ext := filepath.Ext(path)
+	interpreter := r.interpreters[ext]
+	if executable && (interpreter == "" || hasShebang(path)) {
+		return "", nil
+	}
+	if interpreter == "" {
+		return "", fmt.Errorf("%s is not executable and there is no interpreter for %q files", path, ext)
+	}
+	return interpreter, nil
+}
+
+// hasShebang returns true if a file starts with #!.
+func hasShebang(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	magic := make([]byte, 2)
+	if _, err := io.ReadFull(f, magic); err != nil {
+		return false
+	}
+	return string(magic) == "#!"
+}
+
+// Preflight checks that all the scripts that are not skipped can run before
+// running any of them: each script is executable or has an interpreter, and
+// the interpreters exist. The interpreters of the scripts in the host
+// namespaces are resolved in the host and not checked.
+func (r *Run) Preflight(scripts []scriptpkg.Script) error {
+	var problems []string
+	for _, s := range scripts {
+		if s.Skip != "" || s.Command || star.IsCheck(s.Path) {
+			continue
+		}
+		interpreter, err := r.interpreter(s.Path)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", s.Name, err))
+			continue
+		}
+		if interpreter == "" || s.Manifest.HostNamespaces {
+			continue
+		}
+		if _, err := exec.LookPath(interpreter); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: interpreter %q not found", s.Name, interpreter))
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%d scripts can't run: %s", len(problems), strings.Join(problems, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package runner
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/storageos/init/script"
+)
+
+// writeScript writes a script file in a directory with a given content and
+// mode, and returns its path.
+func writeScript(t *testing.T, dir, name, content string, mode os.FileMode) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(content), mode); err != nil {
+		t.Fatalf("failed to write script: %v", err)
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		t.Fatalf("failed to chmod script: %v", err)
+	}
+	return path
+}
+
+func TestInterpreter(t *testing.T) {
+	testcases := []struct {
+		name            string
+		file            string
+		content         string
+		mode            os.FileMode
+		wantInterpreter string
+		wantErr         bool
+	}{
+		{
+			name:    "executable with shebang",
+			file:    "script.sh",
+			content: "#!/bin/sh\necho foo\n",
+			mode:    0755,
+		},
+		{
+			name:            "not executable",
+			file:            "script.sh",
+			content:         "#!/bin/sh\necho foo\n",
+			mode:            0644,
+			wantInterpreter: "/bin/bash",
+		},
+		{
+			name:            "executable without shebang",
+			file:            "script.py",
+			content:         "print('foo')\n",
+			mode:            0755,
+			wantInterpreter: "python3",
+		},
+		{
+			name:    "executable without interpreter",
+			file:    "binary",
+			content: "\x7fELF",
+			mode:    0755,
+		},
+		{
+			name:    "not executable without interpreter",
+			file:    "script.rb",
+			content: "puts 'foo'\n",
+			mode:    0644,
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := writeScript(t, t.TempDir(), tc.file, tc.content, tc.mode)
+
+			interpreter, err := NewRun().interpreter(path)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if interpreter != tc.wantInterpreter {
+				t.Errorf("unexpected interpreter:\n\t(WNT) %q\n\t(GOT) %q", tc.wantInterpreter, interpreter)
+			}
+		})
+	}
+}
+
+func TestRunScriptInterpreter(t *testing.T) {
+	dir := t.TempDir()
+	path := writeScript(t, dir, "script.sh", "echo \"interpreted $1\"\n", 0644)
+
+	run := NewRun().SetOutput(ioutil.Discard, ioutil.Discard)
+	result, err := run.RunScript(script.Script{Path: path, Name: "script"}, nil, "foo")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(result.Stdout) != "interpreted foo\n" {
+		t.Errorf("unexpected stdout:\n\t(WNT) %q\n\t(GOT) %q", "interpreted foo\n", string(result.Stdout))
+	}
+
+	// Without an interpreter, the script doesn't run.
+	run.SetInterpreters(map[string]string{})
+	if result, err := run.RunScript(script.Script{Path: path, Name: "script"}, nil); err == nil || result != nil {
+		t.Errorf("expected an error without result, got %v, %+v", err, result)
+	}
+}
+
+func TestPreflight(t *testing.T) {
+	dir := t.TempDir()
+	executable := writeScript(t, dir, "executable.sh", "#!/bin/sh\n", 0755)
+	shell := writeScript(t, dir, "shell.sh", "echo foo\n", 0644)
+	python := writeScript(t, dir, "python.py", "print('foo')\n", 0644)
+	ruby := writeScript(t, dir, "ruby.rb", "puts 'foo'\n", 0644)
+
+	run := NewRun().SetInterpreters(map[string]string{
+		".sh": "/bin/sh",
+		".py": "no-such-interpreter",
+	})
+
+	scripts := []script.Script{
+		{Path: executable, Name: "executable"},
+		{Path: shell, Name: "shell"},
+		{Path: python, Name: "python"},
+		{Path: ruby, Name: "ruby"},
+		{Path: ruby, Name: "skipped", Skip: "skipped by -skip ruby"},
+		{Path: python, Name: "host", Manifest: script.Manifest{HostNamespaces: true}},
+		{Path: "check.star", Name: "check"},
+	}
+	err := run.Preflight(scripts)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	for _, want := range []string{"2 scripts can't run", `python: interpreter "no-such-interpreter" not found`, "ruby: "} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q in error %q", want, err)
+		}
+	}
+
+	if err := run.Preflight(scripts[:2]); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,61 @@
+package runner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+const (
+	// DefaultNsenter is the default nsenter binary used to run scripts in the
+	// host namespaces.
+	DefaultNsenter = "nsenter"
+	// DefaultNamespaceTarget is the PID of the process whose namespaces are
+	// entered to run scripts in the host namespaces. With hostPID, PID 1 is
+	// the host init process.
+	DefaultNamespaceTarget = 1
+)
+
+// hostNamespaceCommand returns the command name and arguments to execute a
+// script in the mount and PID namespaces of the namespace target process, with
+// an interpreter of the host if not empty.
+//
+// The script file isn't visible from the target mount namespace. It's
+// executed through the root of this process instead, /proc/<pid>/root, which
+// is accessible from the target mount namespace as long as this process is
+// visible in its procfs, i.e. the PID namespace is shared with the target.
+func (r *Run) hostNamespaceCommand(interpreter, script string, arg ...string) (string, []string, error) {
+	abs, err := filepath.Abs(script)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var command []string
+	if interpreter != "" {
+		command = append(command, interpreter)
+	}
+	command = append(command, ownRootPath(abs))
+	name, args := r.nsenterCommand(append(command, arg...)...)
+	return name, args, nil
+}
+
+// nsenterCommand returns the command name and arguments to execute a command
+// in the mount and PID namespaces of the namespace target process. The command
+// is resolved in the target mount namespace, e.g. /bin/sh is the shell of the
+// host.
+func (r *Run) nsenterCommand(command ...string) (string, []string) {
+	args := []string{
+		fmt.Sprintf("--target=%d", r.nsTarget),
+		"--mount",
+		"--pid",
+		"--",
+	}
+	return r.nsenter, append(args, command...)
+}
+
+// ownRootPath returns the path of an absolute path of this process through
+// its root, /proc/<pid>/root, for the scripts in the host namespaces.
+func ownRootPath(abs string) string {
+	return filepath.Join("/proc", strconv.Itoa(os.Getpid()), "root", abs)
+}
@@ -2,85 +2,431 @@
 package runner
 
 import (
-	"bytes"
+	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"log"
 	"os"
 	"os/exec"
 	"sync"
 	"syscall"
+	"time"
+
+	scriptpkg "github.com/storageos/init/script"
+	"github.com/storageos/init/script/lib"
+	"github.com/storageos/init/script/star"
 )
 
+// DefaultOutputDrain is the default time the output of a script is read for
+// after it exits, while a background process it started holds its stdout or
+// stderr open.
+const DefaultOutputDrain = 2 * time.Second
+
 // Run implements Runner interface.
-type Run struct{}
+type Run struct {
+	stdout    io.Writer
+	stderr    io.Writer
+	outMu     sync.Mutex
+	format    Format
+	stripANSI bool
+
+	captureHead int
+	captureTail int
+
+	nsenter  string
+	nsTarget int
+
+	terminationGrace time.Duration
+	outputDrain      time.Duration
+
+	libPath      string
+	artifactsDir string
 
-// NewRun returns an initialized Run.
+	interpreters map[string]string
+
+	// waitMu is held while a script runs, so that the orphaned processes are
+	// not reaped concurrently.
+	waitMu sync.Mutex
+	// mu guards the running script process group or check, the interrupt
+	// signal and the kill timer.
+	mu        sync.Mutex
+	current   int
+	check     *star.Check
+	interrupt syscall.Signal
+	killTimer *time.Timer
+}
+
+// NewRun returns an initialized Run that streams the script output to the
+// system stdout and stderr in text format.
 func NewRun() *Run {
-	return &Run{}
+	return &Run{
+		stdout: os.Stdout,
+		stderr: os.Stderr,
+		format: FormatText,
+
+		captureHead: DefaultCaptureHead,
+		captureTail: DefaultCaptureTail,
+
+		nsenter:  DefaultNsenter,
+		nsTarget: DefaultNamespaceTarget,
+
+		terminationGrace: DefaultTerminationGrace,
+		outputDrain:      DefaultOutputDrain,
+
+		interpreters: DefaultInterpreters,
+	}
+}
+
+// SetOutputDrain sets the time the output of a script is read for after it
+// exits. The output is then closed, even if a background process started by
+// the script still holds it open, e.g. a daemon that didn't redirect it.
+func (r *Run) SetOutputDrain(d time.Duration) *Run {
+	r.outputDrain = d
+	return r
+}
+
+// SetOutput sets the writers the script stdout and stderr lines are streamed
+// to.
+func (r *Run) SetOutput(stdout, stderr io.Writer) *Run {
+	r.stdout = stdout
+	r.stderr = stderr
+	return r
+}
+
+// SetFormat sets the format of the streamed script output lines.
+func (r *Run) SetFormat(format Format) *Run {
+	r.format = format
+	return r
+}
+
+// SetStripANSI sets if ANSI escape sequences, e.g. color codes, should be
+// removed from the streamed script output lines.
+func (r *Run) SetStripANSI(strip bool) *Run {
+	r.stripANSI = strip
+	return r
+}
+
+// SetCaptureLimits sets the number of bytes retained from the start (head)
+// and the end (tail) of each captured script output stream. The rest of the
+// output is discarded from the capture and replaced with a truncation marker.
+// The streamed output is not affected by the limits.
+func (r *Run) SetCaptureLimits(head, tail int) *Run {
+	r.captureHead = head
+	r.captureTail = tail
+	return r
+}
+
+// SetHostNamespaces sets the nsenter binary and the PID of the process whose
+// mount and PID namespaces are entered to run the scripts that require the
+// host namespaces.
+func (r *Run) SetHostNamespaces(nsenter string, target int) *Run {
+	r.nsenter = nsenter
+	r.nsTarget = target
+	return r
+}
+
+// SetTerminationGrace sets the time a script has to exit after a forwarded
+// termination signal before its process group is killed.
+func (r *Run) SetTerminationGrace(grace time.Duration) *Run {
+	r.terminationGrace = grace
+	return r
+}
+
+// SetLibrary sets the path of the bash helper library passed to the scripts
+// in the INIT_LIB env var.
+func (r *Run) SetLibrary(path string) *Run {
+	r.libPath = path
+	return r
+}
+
+// newLineWriter returns a lineWriter for a given script output stream.
+func (r *Run) newLineWriter(out io.Writer, script, stream string) *lineWriter {
+	return &lineWriter{
+		out:       out,
+		mu:        &r.outMu,
+		script:    script,
+		stream:    stream,
+		format:    r.format,
+		stripANSI: r.stripANSI,
+		now:       time.Now,
+	}
 }
 
 // RunScript runs a given script with arguments if specified, and attaches a
-// multiwriter to the stdout and stderr to write to the system pipes and to a
-// buffer to collect the messages. The captured stdout and stderr messages are
-// returned along with any error.
-// The actual logs of the script are still written to the stdout and stderr.
-func (r Run) RunScript(script string, env map[string]string, arg ...string) ([]byte, []byte, error) {
-	cmd := exec.Command(script, arg...)
+// multiwriter to the stdout and stderr to stream the output line by line to the
+// configured writers and to a bounded buffer to collect the messages. Each
+// streamed line is prefixed with a timestamp, the script name and the stream
+// name. The returned Result contains the captured stdout and stderr messages,
+// truncated to the configured capture limits, and the exit status and resource
+// usage of the script. A Result is returned whenever the script was started,
+// along with an error if the script did not exit successfully. Scripts with
+// HostNamespaces set in their manifest are executed in the mount and PID
+// namespaces of the namespace target process via nsenter. Each script runs in
+// its own process group, the target of the forwarded termination signals. Each
+// script gets a private working directory, removed after it runs, and an
+// artifacts directory, preserved in the artifacts directory of the runner if
+// set. A script file that is not executable or doesn't start with a shebang is
+// run with the interpreter of its extension. Starlark checks, with the .star
+// extension, are evaluated in process instead, and cancelled when the runner is
+// interrupted. Once the runner is interrupted, scripts are not started and
+// ErrInterrupted is returned.
+func (r *Run) RunScript(s scriptpkg.Script, env map[string]string, arg ...string) (*scriptpkg.Result, error) {
+	script := s.Path
+
+	r.waitMu.Lock()
+	defer r.waitMu.Unlock()
+
+	if r.Interrupted() != 0 {
+		return nil, scriptpkg.ErrInterrupted
+	}
+
+	if star.IsCheck(script) {
+		return r.runCheck(s, env, arg...)
+	}
+
+	name, args, err := r.command(s, arg...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create the file the script can write its structured result to.
+	resultFile, err := newResultFile()
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(resultFile)
+	resultFileEnv := resultFile
+	if s.Manifest.HostNamespaces {
+		resultFileEnv = ownRootPath(resultFile)
+	}
+
+	// Create the private working directory and the artifacts directory of
+	// the script.
+	ws, err := r.newWorkspace(s)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create script workspace: %v", err)
+	}
+	defer ws.close()
+	workDirEnv, artifactsDirEnv := ws.workDir, ws.artifactsDir
+	if s.Manifest.HostNamespaces {
+		workDirEnv, artifactsDirEnv = ownRootPath(workDirEnv), ownRootPath(artifactsDirEnv)
+	}
+
+	cmd := exec.Command(name, args...)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
 	// Add all env vars.
+	cmd.Env = os.Environ()
 	for k, v := range env {
-		cmd.Env = append(os.Environ(), fmt.Sprintf("%s=%s", k, v))
+		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", k, v))
+	}
+	cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", scriptpkg.ResultFileEnvVar, resultFileEnv))
+	cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", scriptpkg.WorkDirEnvVar, workDirEnv))
+	cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", scriptpkg.ArtifactsEnvVar, artifactsDirEnv))
+	if r.libPath != "" {
+		libPath := r.libPath
+		if s.Manifest.HostNamespaces {
+			libPath = ownRootPath(libPath)
+		}
+		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", lib.EnvVar, libPath))
 	}
 
-	var stdoutBuf, stderrBuf bytes.Buffer
+	stdoutBuf := newCaptureBuffer(r.captureHead, r.captureTail)
+	stderrBuf := newCaptureBuffer(r.captureHead, r.captureTail)
 
-	// Connect to commands stdout and stderr.
-	stdoutIn, _ := cmd.StdoutPipe()
-	stderrIn, _ := cmd.StderrPipe()
+	// Connect to commands stdout and stderr. The pipes are not owned by the
+	// command, so that waiting for the script doesn't wait for the background
+	// processes that inherited them.
+	stdoutIn, stdoutOut, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	defer stdoutIn.Close()
+	stderrIn, stderrOut, err := os.Pipe()
+	if err != nil {
+		stdoutOut.Close()
+		return nil, err
+	}
+	defer stderrIn.Close()
+	cmd.Stdout = stdoutOut
+	cmd.Stderr = stderrOut
 
 	// Setup multi writer to write to stdout/stderr and the buffers.
 	var errStdout, errStderr error
-	stdout := io.MultiWriter(os.Stdout, &stdoutBuf)
-	stderr := io.MultiWriter(os.Stderr, &stderrBuf)
+	stdoutLines := r.newLineWriter(r.stdout, s.Name, streamStdout)
+	stderrLines := r.newLineWriter(r.stderr, s.Name, streamStderr)
+	stdout := io.MultiWriter(stdoutLines, stdoutBuf)
+	stderr := io.MultiWriter(stderrLines, stderrBuf)
 
-	if err := cmd.Start(); err != nil {
+	start := time.Now()
+	err = cmd.Start()
+	stdoutOut.Close()
+	stderrOut.Close()
+	if err != nil {
 		log.Printf("Error while starting %q: %v", script, err)
-		return nil, nil, err
+		return nil, err
 	}
 
-	var wg sync.WaitGroup
-	wg.Add(1)
+	// Track the script process group, forwarding any termination signal
+	// received while the script was starting.
+	r.mu.Lock()
+	r.current = cmd.Process.Pid
+	if r.interrupt != 0 {
+		r.signalCurrent(r.interrupt)
+	}
+	r.mu.Unlock()
 
-	// Wait until the stdout is completely copied.
+	var wg sync.WaitGroup
+	wg.Add(2)
 	go func() {
 		_, errStdout = io.Copy(stdout, stdoutIn)
 		wg.Done()
 	}()
+	go func() {
+		_, errStderr = io.Copy(stderr, stderrIn)
+		wg.Done()
+	}()
+	copied := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(copied)
+	}()
+
+	// Wait for the command to complete, then for its output to be completely
+	// copied. A background process started by the script can hold the output
+	// open: it's closed once drained.
+	waitErr := cmd.Wait()
+	select {
+	case <-copied:
+	case <-time.After(r.outputDrain):
+		log.Printf("%s: output still open %s after exit, held by a background process, closing it", s.Name, r.outputDrain)
+		stdoutIn.Close()
+		stderrIn.Close()
+		<-copied
+	}
+	if errors.Is(errStdout, os.ErrClosed) {
+		errStdout = nil
+	}
+	if errors.Is(errStderr, os.ErrClosed) {
+		errStderr = nil
+	}
+
+	// Write any incomplete last lines.
+	if err := stdoutLines.Flush(); err != nil && errStdout == nil {
+		errStdout = err
+	}
+	if err := stderrLines.Flush(); err != nil && errStderr == nil {
+		errStderr = err
+	}
+
+	r.mu.Lock()
+	r.current = 0
+	interrupted := r.interrupt != 0
+	if r.killTimer != nil {
+		r.killTimer.Stop()
+		r.killTimer = nil
+	}
+	r.mu.Unlock()
 
-	_, errStderr = io.Copy(stderr, stderrIn)
-	wg.Wait()
+	result := newResult(cmd.ProcessState, time.Since(start))
+	result.Stdout = stdoutBuf.Bytes()
+	result.Stderr = stderrBuf.Bytes()
+	result.StdoutTruncated = stdoutBuf.Truncated()
+	result.StderrTruncated = stderrBuf.Truncated()
+	result.Interrupted = interrupted
+	result.Record = readResultFile(resultFile, script)
+	result.Artifacts = ws.close()
 
-	// Wait for the command to complete.
-	if err := cmd.Wait(); err != nil {
-		return stdoutBuf.Bytes(), stderrBuf.Bytes(), err
+	if waitErr != nil {
+		return result, waitErr
 	}
 
 	if errStdout != nil || errStderr != nil {
 		log.Fatalf("failed to capture stdout and stderr\n")
-		return nil, nil, fmt.Errorf("failed to capture stdout and stderr: %v, %v", errStdout, errStderr)
+		return nil, fmt.Errorf("failed to capture stdout and stderr: %v, %v", errStdout, errStderr)
 	}
 
-	return stdoutBuf.Bytes(), stderrBuf.Bytes(), nil
+	return result, nil
 }
 
-// getExitStatus tries to extract exit status from error by type assertions.
-// Returns 0 when no exit status value can be determined.
-func getExitStatus(err error) int {
-	if exiterr, ok := err.(*exec.ExitError); ok {
-		// Extract exit information from the exit error.
-		if status, ok := exiterr.Sys().(syscall.WaitStatus); ok {
-			return status.ExitStatus()
+// command returns the command name and arguments to execute a script with,
+// with the interpreter of the script file if any, in the host namespaces if
+// required by the script manifest. A script that is a command, e.g. the
+// cleanup shell, is run as is, the command of the host in the host
+// namespaces.
+func (r *Run) command(s scriptpkg.Script, arg ...string) (string, []string, error) {
+	if s.Command {
+		if s.Manifest.HostNamespaces {
+			name, args := r.nsenterCommand(append([]string{s.Path}, arg...)...)
+			return name, args, nil
 		}
+		return s.Path, arg, nil
+	}
+
+	interpreter, err := r.interpreter(s.Path)
+	if err != nil {
+		return "", nil, err
+	}
+	if s.Manifest.HostNamespaces {
+		return r.hostNamespaceCommand(interpreter, s.Path, arg...)
+	}
+	if interpreter != "" {
+		return interpreter, append([]string{s.Path}, arg...), nil
+	}
+	return s.Path, arg, nil
+}
+
+// newResultFile creates an empty result file for a script and returns its
+// path.
+func newResultFile() (string, error) {
+	f, err := ioutil.TempFile("", "init-result-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create result file: %v", err)
+	}
+	defer f.Close()
+	return f.Name(), nil
+}
+
+// readResultFile reads and parses the result file of a script. Without a
+// valid record, the result falls back to the exit code and stderr of the
+// script.
+func readResultFile(path, script string) *scriptpkg.Record {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		log.Printf("failed to read result file of %q: %v", script, err)
+		return nil
 	}
-	return 0
+	record, err := scriptpkg.ParseRecord(data)
+	if err != nil {
+		log.Printf("ignoring invalid result file of %q: %v", script, err)
+		return nil
+	}
+	return record
+}
+
+// newResult returns a Result with the exit status and resource usage of a
+// process.
+func newResult(state *os.ProcessState, wallTime time.Duration) *scriptpkg.Result {
+	result := &scriptpkg.Result{
+		WallTime: wallTime,
+	}
+	if state == nil {
+		return result
+	}
+
+	result.ExitCode = state.ExitCode()
+	result.UserTime = state.UserTime()
+	result.SystemTime = state.SystemTime()
+
+	if status, ok := state.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		result.Signal = status.Signal()
+		result.CoreDumped = status.CoreDump()
+	}
+
+	// On linux, ru_maxrss is in kilobytes.
+	if usage, ok := state.SysUsage().(*syscall.Rusage); ok {
+		result.MaxRSS = int64(usage.Maxrss) * 1024
+	}
+
+	return result
 }
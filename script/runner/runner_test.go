@@ -5,7 +5,18 @@ import (
 	"flag"
 	"fmt"
 	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"syscall"
 	"testing"
+	"time"
+
+	"github.com/storageos/init/host"
+	"github.com/storageos/init/script"
+	"github.com/storageos/init/script/lib"
 )
 
 // update flag to update the golden files.
@@ -24,6 +35,7 @@ func TestRunScript(t *testing.T) {
 		scriptArg      string
 		envvars        map[string]string
 		wantExitStatus int
+		wantSignal     syscall.Signal
 	}{
 		{
 			name:           "successful script execution",
@@ -49,6 +61,21 @@ func TestRunScript(t *testing.T) {
 			},
 			wantExitStatus: 0,
 		},
+		{
+			name:       "script with multiple env vars",
+			scriptName: "envvars.sh",
+			envvars: map[string]string{
+				"FOOVAR": "fooval",
+				"BARVAR": "barval",
+			},
+			wantExitStatus: 0,
+		},
+		{
+			name:           "script killed by signal",
+			scriptName:     "signal.sh",
+			wantExitStatus: -1,
+			wantSignal:     syscall.SIGKILL,
+		},
 	}
 
 	for _, tc := range testcases {
@@ -59,7 +86,12 @@ func TestRunScript(t *testing.T) {
 			stderrPath := fmt.Sprintf("%s.%s", scriptPath, "stderr")
 
 			run := NewRun()
-			stdout, stderr, runErr := run.RunScript(scriptPath, tc.envvars, tc.scriptArg)
+			s := script.Script{Path: scriptPath, Name: tc.scriptName}
+			result, runErr := run.RunScript(s, tc.envvars, tc.scriptArg)
+			if result == nil {
+				t.Fatalf("failed to run script: %v", runErr)
+			}
+			stdout, stderr := result.Stdout, result.Stderr
 
 			// Update the golden files if update flag is specified.
 			if *update {
@@ -94,9 +126,327 @@ func TestRunScript(t *testing.T) {
 			}
 
 			// Compare the exit status of the script.
-			exitStatus := getExitStatus(runErr)
-			if tc.wantExitStatus != exitStatus {
-				t.Errorf("unexpected exit status:\n\t(WNT) %d\n\t(GOT) %d", tc.wantExitStatus, exitStatus)
+			if tc.wantExitStatus != result.ExitCode {
+				t.Errorf("unexpected exit status:\n\t(WNT) %d\n\t(GOT) %d", tc.wantExitStatus, result.ExitCode)
+			}
+			if tc.wantSignal != result.Signal {
+				t.Errorf("unexpected signal:\n\t(WNT) %v\n\t(GOT) %v", tc.wantSignal, result.Signal)
+			}
+			if (runErr == nil) != result.Success() {
+				t.Errorf("unexpected error for result %q: %v", result, runErr)
+			}
+		})
+	}
+}
+
+// TestRunScriptHostNamespaces runs a script in the namespaces of a throwaway
+// process in a new user and mount namespace, and checks that the script ran in
+// the mount namespace of the process.
+func TestRunScriptHostNamespaces(t *testing.T) {
+	if _, err := exec.LookPath(DefaultNsenter); err != nil {
+		t.Skipf("nsenter not available: %v", err)
+	}
+
+	// Start the target process in new namespaces.
+	target := exec.Command("unshare", "--user", "--map-root-user", "--mount", "sleep", "60")
+	if err := target.Start(); err != nil {
+		t.Skipf("failed to create namespaces: %v", err)
+	}
+	defer func() {
+		target.Process.Kill()
+		target.Wait()
+	}()
+
+	ownMountNS, err := os.Readlink("/proc/self/ns/mnt")
+	if err != nil {
+		t.Fatalf("failed to read mount namespace: %v", err)
+	}
+
+	// Wait for the target to enter the new mount namespace.
+	var targetMountNS string
+	for i := 0; i < 50; i++ {
+		targetMountNS, _ = os.Readlink(fmt.Sprintf("/proc/%d/ns/mnt", target.Process.Pid))
+		if targetMountNS != "" && targetMountNS != ownMountNS {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if targetMountNS == "" || targetMountNS == ownMountNS {
+		t.Skip("target process did not enter a new mount namespace")
+	}
+
+	run := NewRun().
+		SetOutput(ioutil.Discard, ioutil.Discard).
+		SetHostNamespaces(DefaultNsenter, target.Process.Pid)
+
+	s := script.Script{
+		Path:     "testdata/mountns.sh",
+		Name:     "mountns",
+		Manifest: script.Manifest{HostNamespaces: true},
+	}
+	result, err := run.RunScript(s, nil, "foo")
+	if err != nil {
+		if result != nil {
+			t.Logf("stderr: %s", result.Stderr)
+		}
+		t.Fatalf("failed to run script in namespaces: %v", err)
+	}
+
+	wantStdout := fmt.Sprintf("%s foo\n", targetMountNS)
+	if string(result.Stdout) != wantStdout {
+		t.Errorf("unexpected stdout:\n\t(WNT) %q\n\t(GOT) %q", wantStdout, string(result.Stdout))
+	}
+	if strings.Contains(string(result.Stdout), ownMountNS) {
+		t.Errorf("script ran in the own mount namespace %s", ownMountNS)
+	}
+}
+
+func TestRunScriptResultFile(t *testing.T) {
+	run := NewRun().SetOutput(ioutil.Discard, ioutil.Discard)
+
+	result, err := run.RunScript(script.Script{Path: "testdata/record.sh", Name: "record"}, nil, "5.4")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := &script.Record{
+		Status:  script.RecordWarning,
+		Message: "uio not loaded",
+		Facts:   map[string]string{"kernel": "5.4"},
+	}
+	if !reflect.DeepEqual(result.Record, want) {
+		t.Errorf("unexpected record:\n\t(WNT) %+v\n\t(GOT) %+v", want, result.Record)
+	}
+
+	// Without a record, the result falls back to the exit code.
+	result, err = run.RunScript(script.Script{Path: "testdata/success.sh", Name: "success"}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Record != nil {
+		t.Errorf("unexpected record: %+v", result.Record)
+	}
+}
+
+func TestRunScriptLibrary(t *testing.T) {
+	dir, err := ioutil.TempDir("", "init-lib")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	libPath, err := lib.Install(dir)
+	if err != nil {
+		t.Fatalf("failed to install library: %v", err)
+	}
+
+	run := NewRun().SetOutput(ioutil.Discard, ioutil.Discard).SetLibrary(libPath)
+	result, err := run.RunScript(script.Script{Path: "testdata/library.sh", Name: "library"}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	wantStdout := fmt.Sprintf("lib version %d\n", lib.Version)
+	if string(result.Stdout) != wantStdout {
+		t.Errorf("unexpected stdout:\n\t(WNT) %q\n\t(GOT) %q", wantStdout, string(result.Stdout))
+	}
+	want := &script.Record{
+		Status:      script.RecordWarning,
+		Message:     "uio not loaded",
+		Remediation: "modprobe uio",
+	}
+	if !reflect.DeepEqual(result.Record, want) {
+		t.Errorf("unexpected record:\n\t(WNT) %+v\n\t(GOT) %+v", want, result.Record)
+	}
+}
+
+func TestRunScriptCheck(t *testing.T) {
+	root := "../../host/testdata/root"
+
+	run := NewRun().SetOutput(ioutil.Discard, ioutil.Discard)
+	env := map[string]string{host.EnvVar: root}
+	result, err := run.RunScript(script.Script{Path: "testdata/check.star", Name: "check"}, env, "target_core_mod")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	wantStdout := "module target_core_mod: coming\n"
+	if string(result.Stdout) != wantStdout {
+		t.Errorf("unexpected stdout:\n\t(WNT) %q\n\t(GOT) %q", wantStdout, string(result.Stdout))
+	}
+	want := &script.Record{
+		Status:      script.RecordWarning,
+		Message:     "target_core_mod not loaded",
+		Remediation: "modprobe target_core_mod",
+		Facts:       map[string]string{"root": root},
+	}
+	if !reflect.DeepEqual(result.Record, want) {
+		t.Errorf("unexpected record:\n\t(WNT) %+v\n\t(GOT) %+v", want, result.Record)
+	}
+
+	result, err = run.RunScript(script.Script{Path: "testdata/check-error.star", Name: "check-error"}, env)
+	if err == nil || !strings.Contains(err.Error(), "broken check") {
+		t.Fatalf("unexpected error:\n\t(WNT) %s\n\t(GOT) %v", "broken check", err)
+	}
+	if result == nil || result.ExitCode != 1 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if string(result.Stdout) != "before\n" {
+		t.Errorf("unexpected stdout:\n\t(WNT) %q\n\t(GOT) %q", "before\n", string(result.Stdout))
+	}
+	if !strings.Contains(string(result.Stderr), "broken check") {
+		t.Errorf("expected the error in stderr, got %q", string(result.Stderr))
+	}
+
+	run.Interrupt(syscall.SIGTERM)
+	if _, err := run.RunScript(script.Script{Path: "testdata/check.star", Name: "check"}, env, "loop"); err != script.ErrInterrupted {
+		t.Errorf("unexpected error:\n\t(WNT) %v\n\t(GOT) %v", script.ErrInterrupted, err)
+	}
+}
+
+func TestRunScriptWorkspace(t *testing.T) {
+	artifactsDir := t.TempDir()
+	s := script.Script{Path: "testdata/workspace.sh", RelPath: "workspace/workspace.sh", Name: "workspace"}
+
+	for _, preserve := range []bool{true, false} {
+		t.Run(fmt.Sprintf("preserve %t", preserve), func(t *testing.T) {
+			run := NewRun().SetOutput(ioutil.Discard, ioutil.Discard)
+			if preserve {
+				run.SetArtifactsDir(artifactsDir)
+			}
+
+			result, err := run.RunScript(s, nil)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			dirs := strings.Split(strings.TrimSpace(string(result.Stdout)), "\n")
+			if len(dirs) != 2 {
+				t.Fatalf("unexpected stdout: %q", result.Stdout)
+			}
+			if _, err := os.Stat(dirs[0]); !os.IsNotExist(err) {
+				t.Errorf("expected the work dir %q to be removed, got %v", dirs[0], err)
+			}
+
+			if !preserve {
+				if result.Artifacts != nil {
+					t.Errorf("unexpected artifacts: %v", result.Artifacts)
+				}
+				if _, err := os.Stat(dirs[1]); !os.IsNotExist(err) {
+					t.Errorf("expected the artifacts dir %q to be removed, got %v", dirs[1], err)
+				}
+				return
+			}
+
+			want := []string{filepath.Join(artifactsDir, "workspace", "workspace.sh", "logs", "lsmod.txt")}
+			if !reflect.DeepEqual(result.Artifacts, want) {
+				t.Errorf("unexpected artifacts:\n\t(WNT) %v\n\t(GOT) %v", want, result.Artifacts)
+			}
+			data, err := ioutil.ReadFile(want[0])
+			if err != nil || string(data) != "module list\n" {
+				t.Errorf("unexpected artifact content %q: %v", data, err)
+			}
+
+			// Another script of the same directory keeps the artifacts of
+			// the first one.
+			other := s
+			other.RelPath = "workspace/other.sh"
+			if _, err := run.RunScript(other, nil); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if _, err := os.Stat(want[0]); err != nil {
+				t.Errorf("expected the artifacts of %s to be preserved, got %v", s.RelPath, err)
+			}
+		})
+	}
+}
+
+func TestRunScriptBackgroundProcess(t *testing.T) {
+	run := NewRun().SetOutput(ioutil.Discard, ioutil.Discard).SetOutputDrain(100 * time.Millisecond)
+
+	type runResult struct {
+		result *script.Result
+		err    error
+	}
+	done := make(chan runResult, 1)
+	go func() {
+		result, err := run.RunScript(script.Script{Path: "testdata/background.sh", Name: "background"}, nil)
+		done <- runResult{result, err}
+	}()
+
+	// The script returns while its background process holds its output open.
+	var rr runResult
+	select {
+	case rr = <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for the script with a background process")
+	}
+	if rr.err != nil {
+		t.Fatalf("unexpected error: %v", rr.err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(rr.result.Stdout)), "\n")
+	if len(lines) != 2 || lines[1] != "done" {
+		t.Fatalf("unexpected stdout: %q", rr.result.Stdout)
+	}
+	var pid int
+	if _, err := fmt.Sscan(lines[0], &pid); err != nil {
+		t.Fatalf("invalid background process pid %q: %v", lines[0], err)
+	}
+	syscall.Kill(pid, syscall.SIGKILL)
+}
+
+func TestCommand(t *testing.T) {
+	abs, err := filepath.Abs("testdata/success.sh")
+	if err != nil {
+		t.Fatalf("failed to get absolute path: %v", err)
+	}
+	nsenterArgs := []string{"--target=1", "--mount", "--pid", "--"}
+
+	testcases := []struct {
+		name     string
+		script   script.Script
+		wantName string
+		wantArgs []string
+	}{
+		{
+			name:     "script",
+			script:   script.Script{Path: "testdata/success.sh"},
+			wantName: "testdata/success.sh",
+			wantArgs: []string{"foo"},
+		},
+		{
+			name:     "script in the host namespaces",
+			script:   script.Script{Path: "testdata/success.sh", Manifest: script.Manifest{HostNamespaces: true}},
+			wantName: DefaultNsenter,
+			wantArgs: append(append([]string{}, nsenterArgs...), ownRootPath(abs), "foo"),
+		},
+		{
+			name:     "command",
+			script:   script.Script{Path: script.CleanupShell, Command: true},
+			wantName: script.CleanupShell,
+			wantArgs: []string{"foo"},
+		},
+		{
+			// The shell of the host runs, not the shell of the container
+			// through its root.
+			name:     "command in the host namespaces",
+			script:   script.Script{Path: script.CleanupShell, Command: true, Manifest: script.Manifest{HostNamespaces: true}},
+			wantName: DefaultNsenter,
+			wantArgs: append(append([]string{}, nsenterArgs...), script.CleanupShell, "foo"),
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			name, args, err := NewRun().command(tc.script, "foo")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if name != tc.wantName {
+				t.Errorf("unexpected command name:\n\t(WNT) %s\n\t(GOT) %s", tc.wantName, name)
+			}
+			if !reflect.DeepEqual(args, tc.wantArgs) {
+				t.Errorf("unexpected command args:\n\t(WNT) %v\n\t(GOT) %v", tc.wantArgs, args)
 			}
 		})
 	}
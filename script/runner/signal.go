@@ -0,0 +1,98 @@
+package runner
+
+import (
+	"log"
+	"syscall"
+	"time"
+
+	scriptpkg "github.com/storageos/init/script"
+)
+
+// DefaultTerminationGrace is the default time a script has to exit after a
+// forwarded termination signal before its process group is killed.
+const DefaultTerminationGrace = 10 * time.Second
+
+// prSetChildSubreaper is the prctl option that marks the calling process as a
+// child subreaper, from linux/prctl.h.
+const prSetChildSubreaper = 36
+
+// SetChildSubreaper marks the process as a child subreaper: the orphaned
+// descendants of its children, e.g. the processes a script runs in the
+// background, are reparented to it instead of the PID 1 of the namespace. It
+// has no effect on a process that is already PID 1.
+func SetChildSubreaper() error {
+	if _, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, prSetChildSubreaper, 1, 0); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// Interrupt forwards a termination signal to the process group of the running
+// script, if any, and kills the group if it's still running after the
+// termination grace period. A running check is cancelled. No other script is
+// started once interrupted.
+func (r *Run) Interrupt(sig syscall.Signal) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.interrupt == 0 {
+		r.interrupt = sig
+	}
+	if r.current != 0 {
+		r.signalCurrent(sig)
+	}
+	if r.check != nil {
+		r.check.Cancel(interruptReason(sig))
+	}
+}
+
+// signalCurrent sends a signal to the process group of the running script,
+// and starts the kill timer. It must be called with mu held.
+func (r *Run) signalCurrent(sig syscall.Signal) {
+	pgid := r.current
+	log.Printf("forwarding %s to script process group %d", scriptpkg.SignalName(sig), pgid)
+	if err := syscall.Kill(-pgid, sig); err != nil {
+		log.Printf("failed to signal script process group %d: %v", pgid, err)
+	}
+
+	if r.killTimer != nil {
+		return
+	}
+	r.killTimer = time.AfterFunc(r.terminationGrace, func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if r.current == pgid {
+			log.Printf("script process group %d still running after %s, killing it", pgid, r.terminationGrace)
+			syscall.Kill(-pgid, syscall.SIGKILL)
+		}
+	})
+}
+
+// Interrupted returns the termination signal the runner was interrupted with,
+// or 0.
+func (r *Run) Interrupted() syscall.Signal {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.interrupt
+}
+
+// Reap reaps the exited orphaned processes reparented to this process,
+// returning their number. It waits for the running script to complete, so
+// that the script itself is only waited for by the runner.
+func (r *Run) Reap() int {
+	r.waitMu.Lock()
+	defer r.waitMu.Unlock()
+
+	reaped := 0
+	for {
+		var status syscall.WaitStatus
+		pid, err := syscall.Wait4(-1, &status, syscall.WNOHANG, nil)
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil || pid <= 0 {
+			return reaped
+		}
+		reaped++
+	}
+}
@@ -0,0 +1,110 @@
+package runner
+
+import (
+	"io/ioutil"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/storageos/init/script"
+)
+
+func TestInterrupt(t *testing.T) {
+	testcases := []struct {
+		name       string
+		arg        string
+		wantSignal syscall.Signal
+	}{
+		{
+			name:       "terminated by the forwarded signal",
+			wantSignal: syscall.SIGTERM,
+		},
+		{
+			name:       "killed after the grace period",
+			arg:        "ignore",
+			wantSignal: syscall.SIGKILL,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			run := NewRun().
+				SetOutput(ioutil.Discard, ioutil.Discard).
+				SetTerminationGrace(200 * time.Millisecond)
+
+			s := script.Script{Path: "testdata/interrupt.sh", Name: "interrupt"}
+
+			type runResult struct {
+				result *script.Result
+				err    error
+			}
+			done := make(chan runResult, 1)
+			go func() {
+				result, err := run.RunScript(s, nil, tc.arg)
+				done <- runResult{result, err}
+			}()
+
+			// Wait for the script to start.
+			for i := 0; i < 50; i++ {
+				run.mu.Lock()
+				started := run.current != 0
+				run.mu.Unlock()
+				if started {
+					break
+				}
+				time.Sleep(20 * time.Millisecond)
+			}
+			// Let bash set up the trap.
+			time.Sleep(100 * time.Millisecond)
+
+			run.Interrupt(syscall.SIGTERM)
+
+			var rr runResult
+			select {
+			case rr = <-done:
+			case <-time.After(10 * time.Second):
+				t.Fatal("timed out waiting for the interrupted script")
+			}
+
+			if rr.err == nil {
+				t.Error("expected error for interrupted script")
+			}
+			if rr.result == nil {
+				t.Fatal("expected result for interrupted script")
+			}
+			if !rr.result.Interrupted {
+				t.Error("expected interrupted result")
+			}
+			if rr.result.Signal != tc.wantSignal {
+				t.Errorf("unexpected signal:\n\t(WNT) %s\n\t(GOT) %s", tc.wantSignal, rr.result.Signal)
+			}
+
+			// No script is started once interrupted.
+			if _, err := run.RunScript(s, nil); err != script.ErrInterrupted {
+				t.Errorf("unexpected error after interrupt:\n\t(WNT) %v\n\t(GOT) %v", script.ErrInterrupted, err)
+			}
+		})
+	}
+}
+
+func TestReap(t *testing.T) {
+	if err := SetChildSubreaper(); err != nil {
+		t.Skipf("failed to become child subreaper: %v", err)
+	}
+	defer syscall.RawSyscall(syscall.SYS_PRCTL, prSetChildSubreaper, 0, 0)
+
+	run := NewRun().SetOutput(ioutil.Discard, ioutil.Discard)
+	if _, err := run.RunScript(script.Script{Path: "testdata/orphan.sh", Name: "orphan"}, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Wait for the orphan to exit.
+	reaped := 0
+	for i := 0; i < 50 && reaped == 0; i++ {
+		time.Sleep(50 * time.Millisecond)
+		reaped += run.Reap()
+	}
+	if reaped == 0 {
+		t.Error("expected orphaned process to be reaped")
+	}
+}
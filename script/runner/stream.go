@@ -0,0 +1,152 @@
+package runner
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// Format is the format of the streamed script output lines.
+type Format string
+
+const (
+	// FormatText writes each line as plain text with a timestamp, script and
+	// stream prefix.
+	FormatText Format = "text"
+	// FormatJSON writes each line as a JSON object.
+	FormatJSON Format = "json"
+)
+
+// Names of the script output streams.
+const (
+	streamStdout = "stdout"
+	streamStderr = "stderr"
+	// streamLog is the stream of the init log lines.
+	streamLog = "log"
+)
+
+// maxLineSize is the maximum size of a buffered incomplete line. A longer line
+// is written in parts of maxLineSize bytes, each followed by lineContinued, so
+// that a script output without newlines doesn't grow the buffer unbounded.
+const maxLineSize = 64 * 1024
+
+// lineContinued marks a line part continued on the next line.
+const lineContinued = " [continued]"
+
+// ansiEscape matches ANSI escape sequences, e.g. color codes.
+var ansiEscape = regexp.MustCompile(`\x1b\[[0-9;?]*[ -/]*[@-~]`)
+
+// ParseFormat returns the Format for a given format name.
+func ParseFormat(name string) (Format, error) {
+	switch Format(name) {
+	case FormatText, FormatJSON:
+		return Format(name), nil
+	}
+	return "", fmt.Errorf("unknown log format %q, must be one of %q, %q", name, FormatText, FormatJSON)
+}
+
+// jsonLine is a script output line in JSON format.
+type jsonLine struct {
+	Time   string `json:"time"`
+	Script string `json:"script,omitempty"`
+	Stream string `json:"stream"`
+	Msg    string `json:"msg"`
+}
+
+// NewLogWriter returns a writer for the log package output that writes each
+// log line as a JSON object, like the script output lines in FormatJSON, with
+// the log stream and no script. Use it with no log flags, the time is added
+// to each line.
+func NewLogWriter(out io.Writer) io.Writer {
+	return &lineWriter{
+		out:    out,
+		mu:     &sync.Mutex{},
+		stream: streamLog,
+		format: FormatJSON,
+		now:    time.Now,
+	}
+}
+
+// lineWriter is an io.Writer that buffers the written data and writes it to
+// the underlying writer one line at a time, prefixed with a timestamp, the
+// script name and the stream name. Lines longer than maxLineSize are split.
+// Any trailing incomplete line is written on Flush().
+type lineWriter struct {
+	out       io.Writer
+	mu        *sync.Mutex
+	script    string
+	stream    string
+	format    Format
+	stripANSI bool
+	now       func() time.Time
+
+	buf []byte
+}
+
+// Write implements io.Writer.
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		if err := w.writeLine(w.buf[:i]); err != nil {
+			return 0, err
+		}
+		w.buf = w.buf[i+1:]
+	}
+	for len(w.buf) >= maxLineSize {
+		part := append(w.buf[:maxLineSize:maxLineSize], lineContinued...)
+		if err := w.writeLine(part); err != nil {
+			return 0, err
+		}
+		w.buf = w.buf[maxLineSize:]
+	}
+	// Don't retain the array of the written lines.
+	w.buf = append([]byte(nil), w.buf...)
+	return len(p), nil
+}
+
+// Flush writes any buffered incomplete line.
+func (w *lineWriter) Flush() error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+	err := w.writeLine(w.buf)
+	w.buf = nil
+	return err
+}
+
+// writeLine formats and writes a single line to the underlying writer.
+func (w *lineWriter) writeLine(line []byte) error {
+	line = bytes.TrimSuffix(line, []byte("\r"))
+	if w.stripANSI {
+		line = ansiEscape.ReplaceAll(line, nil)
+	}
+
+	ts := w.now().UTC().Format(time.RFC3339Nano)
+
+	var out []byte
+	switch w.format {
+	case FormatJSON:
+		b, err := json.Marshal(jsonLine{Time: ts, Script: w.script, Stream: w.stream, Msg: string(line)})
+		if err != nil {
+			return err
+		}
+		out = append(b, '\n')
+	default:
+		out = []byte(fmt.Sprintf("%s [%s] %s: %s\n", ts, w.script, w.stream, line))
+	}
+
+	// The stdout and stderr writers of a script may share the same
+	// underlying writer. Avoid interleaving the lines.
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_, err := w.out.Write(out)
+	return err
+}
@@ -0,0 +1,115 @@
+package runner
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLineWriter(t *testing.T) {
+	testTime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	testcases := []struct {
+		name      string
+		writes    []string
+		format    Format
+		stripANSI bool
+		wantOut   string
+	}{
+		{
+			name:   "text lines",
+			writes: []string{"line one\nline two\n"},
+			format: FormatText,
+			wantOut: "2020-01-02T03:04:05Z [foo.sh] stdout: line one\n" +
+				"2020-01-02T03:04:05Z [foo.sh] stdout: line two\n",
+		},
+		{
+			name:   "lines split across writes",
+			writes: []string{"li", "ne one\nline", " two\n"},
+			format: FormatText,
+			wantOut: "2020-01-02T03:04:05Z [foo.sh] stdout: line one\n" +
+				"2020-01-02T03:04:05Z [foo.sh] stdout: line two\n",
+		},
+		{
+			name:    "incomplete last line",
+			writes:  []string{"line one\nno newline"},
+			format:  FormatText,
+			wantOut: "2020-01-02T03:04:05Z [foo.sh] stdout: line one\n" + "2020-01-02T03:04:05Z [foo.sh] stdout: no newline\n",
+		},
+		{
+			name:   "long line split",
+			writes: []string{strings.Repeat("a", maxLineSize-1), "ab", "c\n"},
+			format: FormatText,
+			wantOut: "2020-01-02T03:04:05Z [foo.sh] stdout: " + strings.Repeat("a", maxLineSize) + lineContinued + "\n" +
+				"2020-01-02T03:04:05Z [foo.sh] stdout: bc\n",
+		},
+		{
+			name:    "keep ansi codes",
+			writes:  []string{"\x1b[0;31mERROR\x1b[0m: failed\n"},
+			format:  FormatText,
+			wantOut: "2020-01-02T03:04:05Z [foo.sh] stdout: \x1b[0;31mERROR\x1b[0m: failed\n",
+		},
+		{
+			name:      "strip ansi codes",
+			writes:    []string{"\x1b[0;31mERROR\x1b[0m: failed\n"},
+			format:    FormatText,
+			stripANSI: true,
+			wantOut:   "2020-01-02T03:04:05Z [foo.sh] stdout: ERROR: failed\n",
+		},
+		{
+			name:    "json lines",
+			writes:  []string{"line \"one\"\n"},
+			format:  FormatJSON,
+			wantOut: `{"time":"2020-01-02T03:04:05Z","script":"foo.sh","stream":"stdout","msg":"line \"one\""}` + "\n",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			var out bytes.Buffer
+			w := &lineWriter{
+				out:       &out,
+				mu:        &sync.Mutex{},
+				script:    "foo.sh",
+				stream:    streamStdout,
+				format:    tc.format,
+				stripANSI: tc.stripANSI,
+				now:       func() time.Time { return testTime },
+			}
+
+			for _, data := range tc.writes {
+				if _, err := w.Write([]byte(data)); err != nil {
+					t.Fatalf("unexpected write error: %v", err)
+				}
+			}
+			if err := w.Flush(); err != nil {
+				t.Fatalf("unexpected flush error: %v", err)
+			}
+
+			if out.String() != tc.wantOut {
+				t.Errorf("unexpected output:\n\t(WNT) %q\n\t(GOT) %q", tc.wantOut, out.String())
+			}
+		})
+	}
+}
+
+func TestLogWriter(t *testing.T) {
+	var out bytes.Buffer
+	logger := log.New(NewLogWriter(&out), "", 0)
+	logger.Printf("exec: %s", "01-lio")
+
+	var line jsonLine
+	if err := json.Unmarshal(out.Bytes(), &line); err != nil {
+		t.Fatalf("invalid json line %q: %v", out.String(), err)
+	}
+	if line.Stream != streamLog || line.Script != "" || line.Msg != "exec: 01-lio" || line.Time == "" {
+		t.Errorf("unexpected log line: %+v", line)
+	}
+	if strings.Contains(out.String(), `"script"`) {
+		t.Errorf("unexpected script field in %q", out.String())
+	}
+}
@@ -0,0 +1,6 @@
+#!/bin/bash
+
+# Leave a long-lived background process holding the script output open.
+sleep 3600 &
+echo "$!"
+echo "done"
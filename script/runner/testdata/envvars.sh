@@ -0,0 +1,3 @@
+#!/bin/bash
+
+echo "envvars are $FOOVAR and $BARVAR"
@@ -0,0 +1,10 @@
+#!/bin/bash
+
+# Ignore SIGTERM if asked to, so that only the kill after the grace period
+# terminates the script and its background child.
+if [ "$1" == "ignore" ]; then
+    trap '' TERM
+fi
+
+sleep 60 &
+sleep 60
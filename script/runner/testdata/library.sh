@@ -0,0 +1,6 @@
+#!/bin/bash
+
+. "${INIT_LIB:?INIT_LIB not set}"
+
+echo "lib version $INIT_LIB_VERSION"
+init_warning "uio not loaded" "modprobe uio"
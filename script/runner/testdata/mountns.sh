@@ -0,0 +1,3 @@
+#!/bin/bash
+
+echo "$(readlink /proc/self/ns/mnt) $1"
@@ -0,0 +1,4 @@
+#!/bin/bash
+
+# Leave an orphaned process that exits shortly after the script.
+( sleep 0.2 & )
@@ -0,0 +1,5 @@
+#!/bin/bash
+
+echo "status=warning" >> "$INIT_RESULT_FILE"
+echo "message=uio not loaded" >> "$INIT_RESULT_FILE"
+echo "fact.kernel=$1" >> "$INIT_RESULT_FILE"
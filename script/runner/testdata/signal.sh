@@ -0,0 +1,4 @@
+#!/bin/bash
+
+echo "this script gets killed"
+kill -KILL $$
@@ -0,0 +1,9 @@
+#!/bin/bash
+
+set -e
+
+echo "scratch" > "$INIT_WORKDIR/scratch.txt"
+mkdir -p "$INIT_ARTIFACTS/logs"
+echo "module list" > "$INIT_ARTIFACTS/logs/lsmod.txt"
+echo "$INIT_WORKDIR"
+echo "$INIT_ARTIFACTS"
@@ -0,0 +1,95 @@
+package runner
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+
+	scriptpkg "github.com/storageos/init/script"
+)
+
+// SetArtifactsDir sets the directory the artifacts of the scripts are
+// preserved in, in a subdirectory per script file named after its path
+// relative to its scripts directory, e.g. 01-lio/enable-lio.sh. The artifacts
+// are discarded if not set.
+func (r *Run) SetArtifactsDir(dir string) *Run {
+	r.artifactsDir = dir
+	return r
+}
+
+// workspace contains the private working directory and the artifacts
+// directory of a script run.
+type workspace struct {
+	workDir      string
+	artifactsDir string
+	preserve     bool
+	closed       bool
+}
+
+// newWorkspace creates the workspace of a script. The artifacts directory
+// replaces the artifacts of the previous run of the script file, if
+// preserved. The scripts of the same directory have their own artifacts
+// directory, the script name is only used without a relative path.
+func (r *Run) newWorkspace(s scriptpkg.Script) (*workspace, error) {
+	workDir, err := ioutil.TempDir("", "init-work-")
+	if err != nil {
+		return nil, err
+	}
+	ws := &workspace{workDir: workDir}
+
+	if r.artifactsDir == "" {
+		ws.artifactsDir, err = ioutil.TempDir("", "init-artifacts-")
+	} else {
+		key := s.RelPath
+		if key == "" {
+			key = s.Name
+		}
+		ws.artifactsDir = filepath.Join(r.artifactsDir, key)
+		ws.preserve = true
+		if err = os.RemoveAll(ws.artifactsDir); err == nil {
+			err = os.MkdirAll(ws.artifactsDir, 0755)
+		}
+	}
+	if err != nil {
+		os.RemoveAll(workDir)
+		return nil, err
+	}
+	return ws, nil
+}
+
+// close removes the working directory and returns the paths of the preserved
+// artifacts. The artifacts directory is removed if it's not preserved or
+// contains no file. Closing a closed workspace returns nil.
+func (ws *workspace) close() []string {
+	if ws.closed {
+		return nil
+	}
+	ws.closed = true
+
+	if err := os.RemoveAll(ws.workDir); err != nil {
+		log.Printf("failed to remove work dir %q: %v", ws.workDir, err)
+	}
+
+	var artifacts []string
+	if ws.preserve {
+		err := filepath.Walk(ws.artifactsDir, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !info.IsDir() {
+				artifacts = append(artifacts, path)
+			}
+			return nil
+		})
+		if err != nil {
+			log.Printf("failed to list artifacts in %q: %v", ws.artifactsDir, err)
+		}
+	}
+	if len(artifacts) == 0 {
+		if err := os.RemoveAll(ws.artifactsDir); err != nil {
+			log.Printf("failed to remove artifacts dir %q: %v", ws.artifactsDir, err)
+		}
+	}
+	return artifacts
+}
@@ -7,28 +7,83 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
 
 	_ "github.com/golang/mock/mockgen/model"
 )
 
 // Runner is an interface for script runner.
 type Runner interface {
-	// RunScript executes a script at path script, with environment variables
-	// env and arguments arg, returning stdout and stderr byte slices and any
-	// execution error.
-	RunScript(script string, env map[string]string, arg ...string) (stdout []byte, stderr []byte, err error)
+	// RunScript executes a script, with environment variables env and
+	// arguments arg, returning the Result of the execution and any execution
+	// error. The Result is nil if the script could not be started.
+	RunScript(script Script, env map[string]string, arg ...string) (*Result, error)
 }
 
-// GetAllScripts takes a scripts directory path (absolute path) and scans it for
-// script files, returning a list of all the scripts. It ignores files with docs
-// extensions(.md, .txt).
-func GetAllScripts(scriptsDir string) ([]string, error) {
-	var ignoreFileExt = map[string]bool{
-		".md":  true,
-		".txt": true,
+// Script is an executable script discovered in a scripts directory.
+type Script struct {
+	// Path is the path of the script file.
+	Path string
+	// RelPath is the path of the script file relative to its scripts
+	// directory.
+	RelPath string
+	// Name is the name of the script. For a script in a subdirectory of the
+	// scripts directory, it's the name of the subdirectory, e.g. "01-lio".
+	// Otherwise, it's the script file name without the extension.
+	Name string
+	// Source describes where the script comes from, e.g. the scripts
+	// directory.
+	Source string
+	// Manifest contains the execution settings of the script.
+	Manifest Manifest
+	// Skip is the reason the script must not be executed, if set.
+	Skip string
+	// Env contains the env vars passed to the script only, overriding the
+	// common env vars.
+	Env map[string]string
+	// Args are the arguments the script is run with.
+	Args []string
+	// Command is true if Path is a command of the system the script runs on,
+	// e.g. CleanupShell, instead of a script file. In the host namespaces,
+	// the command of the host is run.
+	Command bool
+}
+
+// String returns the path of the script, followed by its arguments if any.
+func (s Script) String() string {
+	if len(s.Args) == 0 {
+		return s.Path
 	}
+	return s.Path + " " + strings.Join(s.Args, " ")
+}
 
-	allScripts := []string{}
+// scriptName returns the name of a script at path relative to the scripts
+// directory.
+func scriptName(rel string) string {
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	if len(parts) > 1 {
+		return parts[0]
+	}
+	return strings.TrimSuffix(rel, filepath.Ext(rel))
+}
+
+// isDataFile returns true if a file of a scripts directory is not executable,
+// i.e. a docs file, a manifest, a checksums or signature file or a tombstone.
+func isDataFile(name string) bool {
+	if _, exists := docFileExt[filepath.Ext(name)]; exists {
+		return true
+	}
+	return name == ManifestFile || name == ChecksumsFile || name == SignatureFile || filepath.Ext(name) == TombstoneExt
+}
+
+// GetAllScripts takes a scripts directory path (absolute path) and scans it for
+// script files, including the Starlark checks, returning a list of all the
+// scripts. It ignores files with docs extensions(.md, .txt), the manifest
+// files, the checksums and signature files, the tombstone files and the
+// companion cleanup scripts. The manifest of each script is read from the
+// script's directory.
+func GetAllScripts(scriptsDir string) ([]Script, error) {
+	allScripts := []Script{}
 
 	err := filepath.Walk(scriptsDir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
@@ -41,11 +96,30 @@ func GetAllScripts(scriptsDir string) ([]string, error) {
 		}
 
 		// Ignore non-script files.
-		if _, exists := ignoreFileExt[filepath.Ext(path)]; exists {
+		if isDataFile(info.Name()) {
 			return nil
 		}
 
-		allScripts = append(allScripts, path)
+		rel, err := filepath.Rel(scriptsDir, path)
+		if err != nil {
+			return err
+		}
+		if isCleanupScript(rel) {
+			return nil
+		}
+
+		manifest, err := LoadManifest(filepath.Dir(path))
+		if err != nil {
+			return err
+		}
+
+		allScripts = append(allScripts, Script{
+			Path:     path,
+			RelPath:  rel,
+			Name:     scriptName(rel),
+			Source:   scriptsDir,
+			Manifest: manifest,
+		})
 		return nil
 	})
 	if err != nil {
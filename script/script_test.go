@@ -7,6 +7,27 @@ import (
 	"testing"
 )
 
+func TestScriptName(t *testing.T) {
+	testcases := []struct {
+		rel      string
+		wantName string
+	}{
+		{rel: "01-script.sh", wantName: "01-script"},
+		{rel: "01-lio/enable-lio.sh", wantName: "01-lio"},
+		{rel: "10-baz/sub/scripty.sh", wantName: "10-baz"},
+		{rel: "noext", wantName: "noext"},
+		{rel: "03-check.star", wantName: "03-check"},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.rel, func(t *testing.T) {
+			if name := scriptName(tc.rel); name != tc.wantName {
+				t.Errorf("unexpected script name:\n\t(WNT) %s\n\t(GOT) %s", tc.wantName, name)
+			}
+		})
+	}
+}
+
 func TestGetAllScripts(t *testing.T) {
 	testcases := []struct {
 		name               string
@@ -50,6 +71,19 @@ func TestGetAllScripts(t *testing.T) {
 				"a1.sh",
 			},
 		},
+		{
+			name: "starlark checks with scripts",
+			files: []string{
+				"03-mounts/check.star",
+				"02-limits.sh",
+				"01-lio/enable-lio.sh",
+			},
+			wantScriptsInOrder: []string{
+				"enable-lio.sh",
+				"02-limits.sh",
+				"check.star",
+			},
+		},
 		{
 			name:               "no script",
 			files:              []string{},
@@ -69,6 +103,28 @@ func TestGetAllScripts(t *testing.T) {
 				"script10.sh",
 			},
 		},
+		{
+			name: "ignore manifest files",
+			files: []string{
+				"foo/script10.sh",
+				"foo/manifest.yaml",
+			},
+			wantScriptsInOrder: []string{
+				"script10.sh",
+			},
+		},
+		{
+			name: "ignore companion cleanup scripts",
+			files: []string{
+				"foo/script10.sh",
+				"foo/cleanup.sh",
+				"cleanup.sh",
+			},
+			wantScriptsInOrder: []string{
+				"cleanup.sh",
+				"script10.sh",
+			},
+		},
 	}
 
 	for _, tc := range testcases {
@@ -110,8 +166,8 @@ func TestGetAllScripts(t *testing.T) {
 
 			// Check the scripts are in the expected order.
 			for index, script := range scripts {
-				if tc.wantScriptsInOrder[index] != filepath.Base(script) {
-					t.Errorf("unexpected script order at position %d:\n\t(WNT) %s\n\t(GOT) %s", index, tc.wantScriptsInOrder[index], filepath.Base(script))
+				if tc.wantScriptsInOrder[index] != filepath.Base(script.Path) {
+					t.Errorf("unexpected script order at position %d:\n\t(WNT) %s\n\t(GOT) %s", index, tc.wantScriptsInOrder[index], filepath.Base(script.Path))
 				}
 			}
 
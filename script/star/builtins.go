@@ -0,0 +1,256 @@
+package star
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	scriptpkg "github.com/storageos/init/script"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+)
+
+// MaxReadSize is the maximum size of a file read by a check.
+const MaxReadSize = 1 << 20
+
+// hostPath returns the path under the host root of an absolute host path. The
+// symlinks are resolved against the host root, so that the path stays under
+// the root.
+func (c *Check) hostPath(fnname, p string) (string, error) {
+	hostPath, err := c.root.ResolvePath(p)
+	if err != nil {
+		return "", fmt.Errorf("%s: %v", fnname, err)
+	}
+	return hostPath, nil
+}
+
+// envVar implements env(name, default=""), the value of an env var passed to
+// the check.
+func (c *Check) envVar(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var name, def string
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "name", &name, "default?", &def); err != nil {
+		return nil, err
+	}
+	if value, ok := c.env[name]; ok {
+		return starlark.String(value), nil
+	}
+	return starlark.String(def), nil
+}
+
+// exists implements exists(path), true if a host path exists.
+func (c *Check) exists(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var p string
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "path", &p); err != nil {
+		return nil, err
+	}
+	hostPath, err := c.hostPath(b.Name(), p)
+	if err != nil {
+		return nil, err
+	}
+	_, err = os.Stat(hostPath)
+	return starlark.Bool(err == nil), nil
+}
+
+// readFile implements read_file(path, default=None), the content of a host
+// file, or default if the file doesn't exist. Files larger than MaxReadSize
+// are an error.
+func (c *Check) readFile(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var p string
+	var def starlark.Value = starlark.None
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "path", &p, "default?", &def); err != nil {
+		return nil, err
+	}
+	hostPath, err := c.hostPath(b.Name(), p)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(hostPath)
+	if err != nil {
+		if os.IsNotExist(err) && def != starlark.None {
+			return def, nil
+		}
+		return nil, fmt.Errorf("%s: %v", b.Name(), err)
+	}
+	defer f.Close()
+
+	data, err := ioutil.ReadAll(io.LimitReader(f, MaxReadSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", b.Name(), err)
+	}
+	if len(data) > MaxReadSize {
+		return nil, fmt.Errorf("%s: %s larger than %d bytes", b.Name(), p, MaxReadSize)
+	}
+	return starlark.String(data), nil
+}
+
+// listDir implements list_dir(path), the sorted names of the entries of a
+// host directory.
+func (c *Check) listDir(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var p string
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "path", &p); err != nil {
+		return nil, err
+	}
+	hostPath, err := c.hostPath(b.Name(), p)
+	if err != nil {
+		return nil, err
+	}
+
+	infos, err := ioutil.ReadDir(hostPath)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", b.Name(), err)
+	}
+	names := make([]starlark.Value, 0, len(infos))
+	for _, info := range infos {
+		names = append(names, starlark.String(info.Name()))
+	}
+	return starlark.NewList(names), nil
+}
+
+// mountInfo implements mountinfo(pid=1), the mounts of the mount namespace of
+// a host process, as structs with the id, parent_id, major_minor, root,
+// mount_point, options, optional, fstype, source and super_options fields.
+func (c *Check) mountInfo(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	pid := 1
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "pid?", &pid); err != nil {
+		return nil, err
+	}
+
+	mounts, err := c.root.MountInfo(pid)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", b.Name(), err)
+	}
+	values := make([]starlark.Value, 0, len(mounts))
+	for _, m := range mounts {
+		optional := make([]starlark.Value, 0, len(m.Optional))
+		for _, field := range m.Optional {
+			optional = append(optional, starlark.String(field))
+		}
+		values = append(values, starlarkstruct.FromStringDict(starlarkstruct.Default, starlark.StringDict{
+			"id":            starlark.MakeInt(m.ID),
+			"parent_id":     starlark.MakeInt(m.ParentID),
+			"major_minor":   starlark.String(m.MajorMinor),
+			"root":          starlark.String(m.Root),
+			"mount_point":   starlark.String(m.MountPoint),
+			"options":       starlark.String(m.Options),
+			"optional":      starlark.NewList(optional),
+			"fstype":        starlark.String(m.FSType),
+			"source":        starlark.String(m.Source),
+			"super_options": starlark.String(m.SuperOptions),
+		}))
+	}
+	return starlark.NewList(values), nil
+}
+
+// moduleState implements module_state(name), the state of a host kernel
+// module: its initstate, e.g. "live", "builtin", or "" if not loaded.
+func (c *Check) moduleState(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var name string
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "name", &name); err != nil {
+		return nil, err
+	}
+	state, err := c.root.ModuleState(name)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", b.Name(), err)
+	}
+	return starlark.String(state), nil
+}
+
+// hostFacts implements host_facts(), the host features as a struct with the
+// kernel_release, kernel_major, kernel_minor, target_core_user, cgroup_v2 and
+// huge_pages fields.
+func (c *Check) hostFacts(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs); err != nil {
+		return nil, err
+	}
+
+	release, err := c.root.KernelRelease()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", b.Name(), err)
+	}
+	features, err := c.root.Features()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", b.Name(), err)
+	}
+	return starlarkstruct.FromStringDict(starlarkstruct.Default, starlark.StringDict{
+		"kernel_release":   starlark.String(release),
+		"kernel_major":     starlark.MakeInt(features.KernelMajor),
+		"kernel_minor":     starlark.MakeInt(features.KernelMinor),
+		"target_core_user": starlark.Bool(features.TargetCoreUser),
+		"cgroup_v2":        starlark.Bool(features.CgroupV2),
+		"huge_pages":       starlark.Bool(features.HugePages),
+	}), nil
+}
+
+// result implements result(status="", message="", remediation="", facts={},
+// metrics={}, outputs={}), which reports the result of the check like the
+// result file of a script. Successive calls are merged, the last value of each
+// key wins.
+func (c *Check) result(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var next scriptpkg.Record
+	var facts, metrics, outputs *starlark.Dict
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs,
+		"status?", &next.Status,
+		"message?", &next.Message,
+		"remediation?", &next.Remediation,
+		"facts?", &facts,
+		"metrics?", &metrics,
+		"outputs?", &outputs,
+	); err != nil {
+		return nil, err
+	}
+
+	var err error
+	if next.Facts, err = stringMap(facts); err != nil {
+		return nil, fmt.Errorf("%s: facts: %v", b.Name(), err)
+	}
+	if next.Outputs, err = stringMap(outputs); err != nil {
+		return nil, fmt.Errorf("%s: outputs: %v", b.Name(), err)
+	}
+	if metrics != nil {
+		next.Metrics = map[string]float64{}
+		for _, item := range metrics.Items() {
+			key, ok := starlark.AsString(item[0])
+			if !ok {
+				return nil, fmt.Errorf("%s: metrics: got %s key, want string", b.Name(), item[0].Type())
+			}
+			value, ok := starlark.AsFloat(item[1])
+			if !ok {
+				return nil, fmt.Errorf("%s: metrics: got %s value for %q, want number", b.Name(), item[1].Type(), key)
+			}
+			next.Metrics[key] = value
+		}
+	}
+	if err := next.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %v", b.Name(), err)
+	}
+
+	if c.record == nil {
+		c.record = &scriptpkg.Record{}
+	}
+	c.record.Merge(next)
+	return starlark.None, nil
+}
+
+// stringMap converts a dict with string keys to a map. String values are
+// used as is, other values are formatted with str.
+func stringMap(d *starlark.Dict) (map[string]string, error) {
+	if d == nil {
+		return nil, nil
+	}
+	m := map[string]string{}
+	for _, item := range d.Items() {
+		key, ok := starlark.AsString(item[0])
+		if !ok {
+			return nil, fmt.Errorf("got %s key, want string", item[0].Type())
+		}
+		if value, ok := starlark.AsString(item[1]); ok {
+			m[key] = value
+		} else {
+			m[key] = item[1].String()
+		}
+	}
+	return m, nil
+}
@@ -0,0 +1,173 @@
+// Package star evaluates the Starlark check files, sandboxed scripts that
+// inspect the host through a read only builtin API and report their result,
+// without executing any command.
+package star
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+
+	"github.com/storageos/init/host"
+	scriptpkg "github.com/storageos/init/script"
+
+	"go.starlark.net/resolve"
+	"go.starlark.net/starlark"
+)
+
+func init() {
+	// Checks read like scripts: allow the top level if and for statements,
+	// nested functions, lambdas and sets. While loops and recursion stay
+	// disabled.
+	resolve.AllowGlobalReassign = true
+	resolve.AllowNestedDef = true
+	resolve.AllowLambda = true
+	resolve.AllowSet = true
+}
+
+// Ext is the file extension of the Starlark check files.
+const Ext = ".star"
+
+// DefaultMaxSteps is the default execution step budget of a check, enough for
+// any check reading the host state, cancelling a check stuck in a long loop.
+const DefaultMaxSteps = 10 * 1000 * 1000
+
+// IsCheck returns true if a script file is a Starlark check.
+func IsCheck(path string) bool {
+	return strings.HasSuffix(path, Ext)
+}
+
+// Check is a Starlark check file evaluated against a host root.
+type Check struct {
+	path   string
+	root   host.Root
+	env    map[string]string
+	args   []string
+	stdout io.Writer
+	stderr io.Writer
+
+	// maxSteps is the execution step budget of the check, 0 for no limit.
+	maxSteps uint64
+
+	// record is the result reported by the check.
+	record *scriptpkg.Record
+
+	// mu guards the running thread and the cancellation reason.
+	mu     sync.Mutex
+	thread *starlark.Thread
+	cancel string
+}
+
+// NewCheck returns a Check for a check file, inspecting the host mounted at
+// root. The output of the check is discarded.
+func NewCheck(path string, root host.Root) *Check {
+	return &Check{
+		path:     path,
+		root:     root,
+		env:      map[string]string{},
+		stdout:   ioutil.Discard,
+		stderr:   ioutil.Discard,
+		maxSteps: DefaultMaxSteps,
+	}
+}
+
+// SetEnv sets the env vars readable by the check.
+func (c *Check) SetEnv(env map[string]string) *Check {
+	c.env = env
+	return c
+}
+
+// SetArgs sets the arguments of the check, the args global of the check.
+func (c *Check) SetArgs(args ...string) *Check {
+	c.args = args
+	return c
+}
+
+// SetOutput sets the writers the check prints and its errors are written to.
+func (c *Check) SetOutput(stdout, stderr io.Writer) *Check {
+	c.stdout = stdout
+	c.stderr = stderr
+	return c
+}
+
+// SetMaxSteps sets the execution step budget of the check, the check fails
+// with a too many steps error once exceeded. 0 disables the limit.
+func (c *Check) SetMaxSteps(steps uint64) *Check {
+	c.maxSteps = steps
+	return c
+}
+
+// Cancel stops the evaluation of the check with a reason. A check cancelled
+// before it runs fails immediately.
+func (c *Check) Cancel(reason string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.cancel == "" {
+		c.cancel = reason
+	}
+	if c.thread != nil {
+		c.thread.Cancel(reason)
+	}
+}
+
+// Run evaluates the check and returns the result it reported, nil if none,
+// along with any evaluation error. The backtrace of an evaluation error is
+// written to the stderr writer. Loading other modules is not supported.
+func (c *Check) Run() (*scriptpkg.Record, error) {
+	thread := &starlark.Thread{
+		Name: c.path,
+		Print: func(_ *starlark.Thread, msg string) {
+			fmt.Fprintln(c.stdout, msg)
+		},
+	}
+	if c.maxSteps > 0 {
+		thread.SetMaxExecutionSteps(c.maxSteps)
+	}
+
+	c.mu.Lock()
+	c.thread = thread
+	if c.cancel != "" {
+		thread.Cancel(c.cancel)
+	}
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		c.thread = nil
+		c.mu.Unlock()
+	}()
+
+	c.record = nil
+	_, err := starlark.ExecFile(thread, c.path, nil, c.builtins())
+	if err != nil {
+		if evalErr, ok := err.(*starlark.EvalError); ok {
+			fmt.Fprintln(c.stderr, evalErr.Backtrace())
+		} else {
+			fmt.Fprintln(c.stderr, err)
+		}
+	}
+	return c.record, err
+}
+
+// builtins returns the predeclared globals of the check.
+func (c *Check) builtins() starlark.StringDict {
+	args := make(starlark.Tuple, 0, len(c.args))
+	for _, arg := range c.args {
+		args = append(args, starlark.String(arg))
+	}
+
+	return starlark.StringDict{
+		"args":         args,
+		"env":          starlark.NewBuiltin("env", c.envVar),
+		"exists":       starlark.NewBuiltin("exists", c.exists),
+		"read_file":    starlark.NewBuiltin("read_file", c.readFile),
+		"list_dir":     starlark.NewBuiltin("list_dir", c.listDir),
+		"mountinfo":    starlark.NewBuiltin("mountinfo", c.mountInfo),
+		"module_state": starlark.NewBuiltin("module_state", c.moduleState),
+		"host_facts":   starlark.NewBuiltin("host_facts", c.hostFacts),
+		"result":       starlark.NewBuiltin("result", c.result),
+	}
+}
@@ -0,0 +1,259 @@
+package star
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/storageos/init/host"
+	"github.com/storageos/init/script"
+)
+
+// testRoot is a host root with kernel modules, mounts and host features.
+const testRoot = "../../host/testdata/root"
+
+// writeCheck writes a check file with a given source and returns its path.
+func writeCheck(t *testing.T, src string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "check"+Ext)
+	if err := ioutil.WriteFile(path, []byte(src), 0644); err != nil {
+		t.Fatalf("failed to write check: %v", err)
+	}
+	return path
+}
+
+func TestIsCheck(t *testing.T) {
+	testcases := map[string]bool{
+		"scripts/03-mounts/check.star": true,
+		"scripts/01-lio/enable-lio.sh": false,
+		"check.star.md":                false,
+	}
+
+	for path, want := range testcases {
+		if got := IsCheck(path); got != want {
+			t.Errorf("unexpected IsCheck(%q):\n\t(WNT) %t\n\t(GOT) %t", path, want, got)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	testcases := []struct {
+		name       string
+		src        string
+		env        map[string]string
+		args       []string
+		wantRecord *script.Record
+		wantStdout string
+		wantErr    string
+	}{
+		{
+			name:       "no result",
+			src:        `print("hello")`,
+			wantStdout: "hello\n",
+		},
+		{
+			name: "merged results",
+			src: `
+result(status = "warning", message = "first", facts = {"a": "1", "b": 2})
+result(message = "second", metrics = {"m": 1.5, "n": 3}, outputs = {"OUT": "x"})
+`,
+			wantRecord: &script.Record{
+				Status:  script.RecordWarning,
+				Message: "second",
+				Facts:   map[string]string{"a": "1", "b": "2"},
+				Metrics: map[string]float64{"m": 1.5, "n": 3},
+				Outputs: map[string]string{"OUT": "x"},
+			},
+		},
+		{
+			name: "env and args",
+			src: `
+result(facts = {"foo": env("FOO"), "bar": env("BAR", "none"), "args": ",".join(args)})
+`,
+			env:  map[string]string{"FOO": "fooval"},
+			args: []string{"a", "b"},
+			wantRecord: &script.Record{
+				Facts: map[string]string{"foo": "fooval", "bar": "none", "args": "a,b"},
+			},
+		},
+		{
+			name: "host files",
+			src: `
+result(facts = {
+    "release": read_file("/proc/sys/kernel/osrelease").strip(),
+    "missing": read_file("/nope", default = "default"),
+    "modules": ",".join(list_dir("/sys/module")),
+    "exists": exists("/sys/module/configfs"),
+    "escaped": exists("/../../../host/testdata/root/sys"),
+})
+`,
+			wantRecord: &script.Record{
+				Facts: map[string]string{
+					"release": "5.4.0-42-generic",
+					"missing": "default",
+					"modules": "configfs,loop,target_core_mod",
+					"exists":  "True",
+					"escaped": "False",
+				},
+			},
+		},
+		{
+			name: "host state",
+			src: `
+mounts = {m.mount_point: m for m in mountinfo()}
+facts = host_facts()
+result(facts = {
+    "configfs": mounts["/sys/kernel/config"].fstype,
+    "optional": " ".join(mounts["/var/lib/storageos"].optional),
+    "lio": module_state("target_core_mod"),
+    "loop": module_state("loop"),
+    "kernel": "%d.%d" % (facts.kernel_major, facts.kernel_minor),
+    "cgroup_v2": facts.cgroup_v2,
+})
+`,
+			wantRecord: &script.Record{
+				Facts: map[string]string{
+					"configfs":  "configfs",
+					"optional":  "shared:20 master:1",
+					"lio":       "coming",
+					"loop":      host.ModuleBuiltin,
+					"kernel":    "5.4",
+					"cgroup_v2": "True",
+				},
+			},
+		},
+		{
+			name:    "relative path",
+			src:     `read_file("proc/sys/kernel/osrelease")`,
+			wantErr: `path "proc/sys/kernel/osrelease" is not absolute`,
+		},
+		{
+			name:    "invalid status",
+			src:     `result(status = "great")`,
+			wantErr: `invalid status "great"`,
+		},
+		{
+			name:    "invalid output name",
+			src:     `result(outputs = {"NOT-VALID": "x"})`,
+			wantErr: `invalid output name "NOT-VALID"`,
+		},
+		{
+			name: "result kept on failure",
+			src: `
+result(status = "failed", message = "broken")
+fail("boom")
+`,
+			wantRecord: &script.Record{Status: script.RecordFailed, Message: "broken"},
+			wantErr:    "boom",
+		},
+		{
+			name:    "load not supported",
+			src:     `load("other.star", "x")`,
+			wantErr: "load not implemented",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			check := NewCheck(writeCheck(t, tc.src), host.NewRoot(testRoot)).
+				SetArgs(tc.args...).
+				SetOutput(&stdout, &stderr)
+			if tc.env != nil {
+				check.SetEnv(tc.env)
+			}
+
+			record, err := check.Run()
+			if tc.wantErr == "" && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("unexpected error:\n\t(WNT) %s\n\t(GOT) %v", tc.wantErr, err)
+				}
+				if !strings.Contains(stderr.String(), tc.wantErr) {
+					t.Errorf("expected the error in stderr, got %q", stderr.String())
+				}
+			}
+
+			if !reflect.DeepEqual(record, tc.wantRecord) {
+				t.Errorf("unexpected record:\n\t(WNT) %+v\n\t(GOT) %+v", tc.wantRecord, record)
+			}
+			if stdout.String() != tc.wantStdout {
+				t.Errorf("unexpected stdout:\n\t(WNT) %q\n\t(GOT) %q", tc.wantStdout, stdout.String())
+			}
+		})
+	}
+}
+
+func TestCancel(t *testing.T) {
+	check := NewCheck(writeCheck(t, `
+def loop():
+    for i in range(1000000000):
+        pass
+loop()
+`), host.NewRoot(testRoot))
+
+	done := make(chan error)
+	go func() {
+		_, err := check.Run()
+		done <- err
+	}()
+	check.Cancel("interrupted by SIGTERM")
+
+	if err := <-done; err == nil || !strings.Contains(err.Error(), "interrupted by SIGTERM") {
+		t.Errorf("unexpected error:\n\t(WNT) %s\n\t(GOT) %v", "interrupted by SIGTERM", err)
+	}
+}
+
+func TestMaxSteps(t *testing.T) {
+	src := `
+for i in range(1000000):
+    pass
+`
+	_, err := NewCheck(writeCheck(t, src), host.NewRoot(testRoot)).SetMaxSteps(1000).Run()
+	if err == nil || !strings.Contains(err.Error(), "too many steps") {
+		t.Errorf("unexpected error:\n\t(WNT) %s\n\t(GOT) %v", "too many steps", err)
+	}
+
+	// Without a limit, the loop completes.
+	if _, err := NewCheck(writeCheck(t, src), host.NewRoot(testRoot)).SetMaxSteps(0).Run(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestHostSymlinks(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "etc"), 0755); err != nil {
+		t.Fatalf("failed to create directory: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(root, "etc", "real.conf"), []byte("host"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	// The absolute symlink targets resolve against the host root, not the
+	// root of the init container.
+	if err := os.Symlink("/etc/real.conf", filepath.Join(root, "etc", "link.conf")); err != nil {
+		t.Fatalf("failed to create symlink: %v", err)
+	}
+	if err := os.Symlink("/etc", filepath.Join(root, "config")); err != nil {
+		t.Fatalf("failed to create symlink: %v", err)
+	}
+
+	src := `
+print(read_file("/etc/link.conf"))
+print(exists("/config/real.conf"))
+print(list_dir("/config"))
+`
+	var stdout bytes.Buffer
+	if _, err := NewCheck(writeCheck(t, src), host.NewRoot(root)).SetOutput(&stdout, ioutil.Discard).Run(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "host\nTrue\n[\"link.conf\", \"real.conf\"]\n"
+	if stdout.String() != want {
+		t.Errorf("unexpected stdout:\n\t(WNT) %q\n\t(GOT) %q", want, stdout.String())
+	}
+}
@@ -0,0 +1,220 @@
+package script
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	// ChecksumsFile is the name of the file at the top level of a scripts
+	// directory that lists the SHA-256 digests of the files in the
+	// directory, in the sha256sum format.
+	ChecksumsFile = "SHA256SUMS"
+	// SignatureFile is the name of the file that contains the base64 encoded
+	// ed25519 detached signature of the checksums file.
+	SignatureFile = ChecksumsFile + ".sig"
+)
+
+// VerificationError is returned when the files of a scripts directory don't
+// match its checksums.
+type VerificationError struct {
+	// Dir is the scripts directory.
+	Dir string
+	// Failures describes each file that failed the verification.
+	Failures []string
+}
+
+// Error implements error.
+func (e *VerificationError) Error() string {
+	return fmt.Sprintf("verification of scripts dir %q failed: %s", e.Dir, strings.Join(e.Failures, ", "))
+}
+
+// Verifier verifies the integrity of the files in a scripts directory against
+// the checksums file, and optionally the signature of the checksums file.
+type Verifier struct {
+	publicKey ed25519.PublicKey
+}
+
+// NewVerifier returns an initialized Verifier.
+func NewVerifier() *Verifier {
+	return &Verifier{}
+}
+
+// SetPublicKey sets the public key used to verify the signature of the
+// checksums files. When set, the signature is required.
+func (v *Verifier) SetPublicKey(key ed25519.PublicKey) *Verifier {
+	v.publicKey = key
+	return v
+}
+
+// ParsePublicKey parses a PEM encoded PKIX ed25519 public key.
+func ParsePublicKey(data []byte) (ed25519.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	edKey, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("unsupported public key type %T, must be ed25519", key)
+	}
+	return edKey, nil
+}
+
+// Verify checks that every file in a scripts directory, except the docs files,
+// is listed in the checksums file with a matching digest, and that all the
+// listed files exist. If a public key is set, the signature of the checksums
+// file is verified first. A VerificationError lists all the files that failed.
+func (v *Verifier) Verify(scriptsDir string) error {
+	sums, err := ioutil.ReadFile(filepath.Join(scriptsDir, ChecksumsFile))
+	if err != nil {
+		return fmt.Errorf("failed to read checksums of scripts dir %q: %v", scriptsDir, err)
+	}
+
+	if v.publicKey != nil {
+		if err := v.verifySignature(scriptsDir, sums); err != nil {
+			return err
+		}
+	}
+
+	want, err := parseChecksums(sums)
+	if err != nil {
+		return fmt.Errorf("invalid checksums of scripts dir %q: %v", scriptsDir, err)
+	}
+
+	var failures []string
+	seen := map[string]bool{}
+
+	err = filepath.Walk(scriptsDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(scriptsDir, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if rel == ChecksumsFile || rel == SignatureFile || docFileExt[filepath.Ext(path)] {
+			return nil
+		}
+		seen[rel] = true
+
+		digest, ok := want[rel]
+		if !ok {
+			failures = append(failures, fmt.Sprintf("%s: not listed", rel))
+			return nil
+		}
+
+		got, err := fileDigest(path)
+		if err != nil {
+			return err
+		}
+		if got != digest {
+			failures = append(failures, fmt.Sprintf("%s: digest mismatch", rel))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for rel := range want {
+		if !seen[rel] {
+			failures = append(failures, fmt.Sprintf("%s: missing", rel))
+		}
+	}
+
+	if len(failures) > 0 {
+		sort.Strings(failures)
+		return &VerificationError{Dir: scriptsDir, Failures: failures}
+	}
+
+	return nil
+}
+
+// verifySignature verifies the signature of the checksums file.
+func (v *Verifier) verifySignature(scriptsDir string, sums []byte) error {
+	data, err := ioutil.ReadFile(filepath.Join(scriptsDir, SignatureFile))
+	if err != nil {
+		return fmt.Errorf("failed to read checksums signature of scripts dir %q: %v", scriptsDir, err)
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return fmt.Errorf("invalid checksums signature of scripts dir %q: %v", scriptsDir, err)
+	}
+
+	if !ed25519.Verify(v.publicKey, sums, sig) {
+		return &VerificationError{Dir: scriptsDir, Failures: []string{fmt.Sprintf("%s: bad signature", ChecksumsFile)}}
+	}
+
+	return nil
+}
+
+// parseChecksums parses the content of a checksums file in the sha256sum
+// format, "<hex digest>  <path>" per line, returning the digests by slash
+// separated relative path.
+func parseChecksums(data []byte) (map[string]string, error) {
+	sums := map[string]string{}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		parts := strings.SplitN(text, " ", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("line %d: expected digest and path", line)
+		}
+		digest := strings.ToLower(parts[0])
+		if b, err := hex.DecodeString(digest); err != nil || len(b) != sha256.Size {
+			return nil, fmt.Errorf("line %d: invalid SHA-256 digest %q", line, parts[0])
+		}
+
+		// The path is prefixed with "*" in binary mode.
+		path := strings.TrimPrefix(strings.TrimLeft(parts[1], " "), "*")
+		path = strings.TrimPrefix(filepath.ToSlash(filepath.Clean(path)), "./")
+		sums[path] = digest
+	}
+
+	return sums, scanner.Err()
+}
+
+// fileDigest returns the hex encoded SHA-256 digest of a file.
+func fileDigest(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,167 @@
+package script
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// checksumLine returns a checksums file line for a file content.
+func checksumLine(path, content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return fmt.Sprintf("%s  %s\n", hex.EncodeToString(sum[:]), path)
+}
+
+func TestVerify(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	// Files written in the scripts directory for all the test cases.
+	files := map[string]string{
+		"01-lio/enable-lio.sh": "#!/bin/bash\necho lio\n",
+		"01-lio/manifest.yaml": "hostNamespaces: true\n",
+		"02-limits/limits.sh":  "#!/bin/bash\necho limits\n",
+		"02-limits/README.md":  "docs are not verified\n",
+		"03-foo.sh":            "#!/bin/bash\necho foo\n",
+		"02-limits/notes.txt":  "neither are notes\n",
+	}
+	validSums := checksumLine("01-lio/enable-lio.sh", files["01-lio/enable-lio.sh"]) +
+		checksumLine("./01-lio/manifest.yaml", files["01-lio/manifest.yaml"]) +
+		checksumLine("02-limits/limits.sh", files["02-limits/limits.sh"]) +
+		checksumLine("*03-foo.sh", files["03-foo.sh"])
+
+	testcases := []struct {
+		name         string
+		sums         string
+		signature    string
+		publicKey    ed25519.PublicKey
+		wantErr      bool
+		wantFailures []string
+	}{
+		{
+			name: "all files match",
+			sums: validSums,
+		},
+		{
+			name: "modified unlisted and missing files",
+			sums: checksumLine("01-lio/enable-lio.sh", "#!/bin/bash\necho original\n") +
+				checksumLine("01-lio/manifest.yaml", files["01-lio/manifest.yaml"]) +
+				checksumLine("02-limits/limits.sh", files["02-limits/limits.sh"]) +
+				checksumLine("04-gone.sh", ""),
+			wantErr: true,
+			wantFailures: []string{
+				"01-lio/enable-lio.sh: digest mismatch",
+				"03-foo.sh: not listed",
+				"04-gone.sh: missing",
+			},
+		},
+		{
+			name:    "invalid checksums",
+			sums:    "not-a-digest  03-foo.sh\n",
+			wantErr: true,
+		},
+		{
+			name:      "valid signature",
+			sums:      validSums,
+			signature: base64.StdEncoding.EncodeToString(ed25519.Sign(privateKey, []byte(validSums))),
+			publicKey: publicKey,
+		},
+		{
+			name:         "bad signature",
+			sums:         validSums,
+			signature:    base64.StdEncoding.EncodeToString(ed25519.Sign(privateKey, []byte("other"))),
+			publicKey:    publicKey,
+			wantErr:      true,
+			wantFailures: []string{"SHA256SUMS: bad signature"},
+		},
+		{
+			name:      "missing signature",
+			sums:      validSums,
+			publicKey: publicKey,
+			wantErr:   true,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "init-verify-test")
+			if err != nil {
+				t.Fatalf("failed to create directory: %v", err)
+			}
+			defer os.RemoveAll(dir)
+
+			for name, content := range files {
+				path := filepath.Join(dir, name)
+				if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+					t.Fatalf("failed to create sub directory: %v", err)
+				}
+				if err := ioutil.WriteFile(path, []byte(content), 0755); err != nil {
+					t.Fatalf("failed to create file: %v", err)
+				}
+			}
+			if err := ioutil.WriteFile(filepath.Join(dir, ChecksumsFile), []byte(tc.sums), 0644); err != nil {
+				t.Fatalf("failed to create checksums file: %v", err)
+			}
+			if tc.signature != "" {
+				if err := ioutil.WriteFile(filepath.Join(dir, SignatureFile), []byte(tc.signature+"\n"), 0644); err != nil {
+					t.Fatalf("failed to create signature file: %v", err)
+				}
+			}
+
+			v := NewVerifier()
+			if tc.publicKey != nil {
+				v.SetPublicKey(tc.publicKey)
+			}
+
+			err = v.Verify(dir)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if tc.wantFailures != nil {
+				verr, ok := err.(*VerificationError)
+				if !ok {
+					t.Fatalf("expected VerificationError, got: %v", err)
+				}
+				if !reflect.DeepEqual(verr.Failures, tc.wantFailures) {
+					t.Errorf("unexpected failures:\n\t(WNT) %v\n\t(GOT) %v", tc.wantFailures, verr.Failures)
+				}
+			}
+		})
+	}
+}
+
+func TestParsePublicKey(t *testing.T) {
+	publicKey, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+
+	key, err := ParsePublicKey(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !key.Equal(publicKey) {
+		t.Errorf("unexpected public key:\n\t(WNT) %x\n\t(GOT) %x", publicKey, key)
+	}
+
+	if _, err := ParsePublicKey([]byte("not a key")); err == nil {
+		t.Error("expected error for invalid PEM data")
+	}
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"embed"
+	"io/fs"
+
+	"github.com/storageos/init/script"
+)
+
+// embeddedScriptsDir is the directory of the embedded scripts.
+const embeddedScriptsDir = "scripts"
+
+// embeddedScripts are the stock scripts, run when no scripts directory is
+// passed.
+//
+//go:embed scripts
+var embeddedScripts embed.FS
+
+// extractScripts writes the embedded scripts into dir.
+func extractScripts(dir string) error {
+	fsys, err := fs.Sub(embeddedScripts, embeddedScriptsDir)
+	if err != nil {
+		return err
+	}
+	return script.Extract(fsys, dir)
+}
@@ -0,0 +1,35 @@
+#!/bin/bash
+
+# Undo the changes of enable-lio.sh when StorageOS is removed from the host.
+# Run by "init cleanup". A module still in use, e.g. by LIO objects left in
+# configfs, is kept loaded.
+
+# HOST_ROOT is the prefix where the host root filesystem is mounted, if any.
+# It's empty when running in the host namespaces.
+sys_dir="${HOST_ROOT}/sys"
+modules_load_dir="${HOST_ROOT}/etc/modules-load.d"
+
+if [ -f "$modules_load_dir"/lio.conf ]; then
+    echo "Removing $modules_load_dir/lio.conf"
+    rm -f "$modules_load_dir"/lio.conf
+fi
+
+# Remove the loopback dir created by enable-lio.sh, it fails if the loopback
+# fabric still has targets.
+loop_dir="$sys_dir"/kernel/config/target/loopback
+if [ -d "$loop_dir" ]; then
+    echo "Removing $loop_dir"
+    rmdir "$loop_dir" || echo "WARNING: $loop_dir is in use"
+fi
+
+# Unload the modules in the reverse order of loading. configfs is left alone,
+# other subsystems use it.
+for mod in target_core_user uio target_core_file tcm_loop target_core_mod; do
+    state_file=$sys_dir/module/$mod/initstate
+    if [ -f "$state_file" ] && grep -q live "$state_file"; then
+        echo "Unloading module $mod"
+        modprobe -r $mod || echo "WARNING: Couldn't unload $mod, it may be in use"
+    fi
+done
+
+echo "LIO cleanup done"
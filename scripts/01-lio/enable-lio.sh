@@ -2,71 +2,71 @@
 
 set -e
 
-function module_error_log() {
-    local mod="$1"
-    local mod_dir="$2"
-    RE='\e[0;31m'
-    NC='\e[0m' # No Color
-    echo -e "${RE}ERROR: The kernel module $mod couldn't load properly. Please try to run${NC} modprobe $mod ${RE}. Once loaded, the directory $mod_dir should be accessible. Otherwise the module has not been loaded as expected.${NC}"
-}
+. "${INIT_LIB:?INIT_LIB not set, run the script with init}"
 
-# Configfs can be built in the kernel, hence the module 
+# HOST_ROOT is the prefix where the host root filesystem is mounted, if any.
+# It's empty when running in the host namespaces.
+sys_dir="${HOST_ROOT}/sys"
+
+# Configfs can be built in the kernel, hence the module
 # initstate file will not exist. Even though, the mount
 # is present and working
-echo "Checking configfs"
-if mount | grep -q "^configfs on /sys/kernel/config"; then
-    echo "configfs mounted on sys/kernel/config"
+init_log_info "Checking configfs"
+if mount | grep -q "^configfs on $sys_dir/kernel/config"; then
+    init_log_info "configfs mounted on sys/kernel/config"
 else
-    echo "configfs not mounted, checking if kmod is loaded"
-    state_file=/sys/module/configfs/initstate
-    if [ -f "$state_file" ] && grep -q live "$state_file"; then
-        echo "configfs mod is loaded"
-    else
-        echo "configfs not loaded, executing: modprobe -b configfs"
-        modprobe -b configfs
+    init_log_info "configfs not mounted, checking if kmod is loaded"
+    if ! init_module_load configfs; then
+        init_fail "The kernel module configfs couldn't load" "modprobe configfs"
     fi
 
     if mount | grep -q configfs; then
-        echo "configfs mounted"
+        init_log_info "configfs mounted"
     else
-        echo "mounting configfs /sys/kernel/config"
-        mount -t configfs configfs /sys/kernel/config
+        init_log_info "mounting configfs $sys_dir/kernel/config"
+        mount -t configfs configfs "$sys_dir"/kernel/config
     fi
 fi
 
-target_dir=/sys/kernel/config/target
+target_dir="$sys_dir"/kernel/config/target
 core_dir="$target_dir"/core
 loop_dir="$target_dir"/loopback
 
 # Enable a mod if not present
 # /sys/module/$modname/initstate has got the word "live"
-# in case the kernel module is loaded and running 
+# in case the kernel module is loaded and running
 for mod in target_core_mod tcm_loop target_core_file uio target_core_user; do
-    state_file=/sys/module/$mod/initstate
-    if [ -f "$state_file" ] && grep -q live "$state_file"; then
-        echo "Module $mod is running"
-    else 
-        echo "Module $mod is not running"
-        echo "--> executing \"modprobe -b $mod\""
-        if ! modprobe -b $mod; then
-            # core_user and uio are not mandatory
-            if [ "$mod" != "target_core_user" ] && [ "$mod" != "uio" ]; then
-                exit 1
-            else 
-                echo "Couldn't enable $mod"
-            fi
+    if init_module_loaded "$mod"; then
+        init_log_info "Module $mod is running"
+        continue
+    fi
+    if ! init_module_load "$mod"; then
+        # core_user and uio are not mandatory
+        if [ "$mod" != "target_core_user" ] && [ "$mod" != "uio" ]; then
+            init_fail "The kernel module $mod couldn't load" "modprobe $mod"
         fi
-        # Enable module at boot
-        mkdir -p /etc/modules-load.d
-        [ ! -f /etc/modules-load.d/lio.conf ] && echo $mod >> /etc/modules-load.d/lio.conf # create file if doesn't exist
+        init_warning "Couldn't enable $mod" "modprobe $mod"
     fi
+    # Enable module at boot
+    init_module_persist "$mod" lio.conf
 done
 
 # Check if the modules loaded have its
-# directories available on top of configfs
+# directories available on top of configfs. Once loaded, the directories
+# should be accessible. Otherwise the modules have not been loaded as expected.
+[ ! -d "$target_dir" ] && init_warning "$target_dir doesn't exist, target_core_mod couldn't load properly" "modprobe target_core_mod"
+[ ! -d "$core_dir" ]   && init_warning "$core_dir doesn't exist, target_core_file couldn't load properly" "modprobe target_core_file"
+[ ! -d "$loop_dir" ]   && init_log_info "$loop_dir doesn't exist. Creating dir manually..." && mkdir "$loop_dir"
+
+# Publish the availability of the optional user backstore to the scripts that
+# run after this one.
+if init_module_loaded target_core_user; then
+    init_output LIO_USER_BACKSTORE available
+else
+    init_output LIO_USER_BACKSTORE unavailable
+fi
 
-[ ! -d "$target_dir" ] && echo "$target_dir doesn't exist" && module_error_log "target_core_mod" "$target_dir"
-[ ! -d "$core_dir" ]   && echo "$core_dir doesn't exist"   && module_error_log "target_core_file" "$core_dir"
-[ ! -d "$loop_dir" ]   && echo "$loop_dir doesn't exist. Creating dir manually..." && mkdir $loop_dir
+# Keep the loaded kernel modules for troubleshooting.
+init_artifact lsmod.txt lsmod
 
-echo "LIO set up is ready!"
+init_log_info "LIO set up is ready!"
@@ -0,0 +1,159 @@
+// Package watch periodically re-runs the host checks and serves their state
+// over HTTP for the liveness and readiness probes.
+package watch
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/storageos/init/report"
+)
+
+const (
+	// DefaultInterval is the default interval between two checks.
+	DefaultInterval = 5 * time.Minute
+	// DefaultHealthAddr is the default address of the health server.
+	DefaultHealthAddr = ":8080"
+
+	// staleIntervals is the number of intervals without a completed check
+	// after which the watcher is not healthy, e.g. a check hangs.
+	staleIntervals = 3
+)
+
+// CheckFunc runs the checks and returns their report.
+type CheckFunc func() *report.Report
+
+// ChangeFunc is called with the report of a check when the state of the
+// checks changed.
+type ChangeFunc func(r *report.Report)
+
+// Watcher runs the checks periodically and keeps the last report.
+type Watcher struct {
+	check    CheckFunc
+	interval time.Duration
+	onChange ChangeFunc
+	now      func() time.Time
+
+	mu        sync.Mutex
+	last      *report.Report
+	lastCheck time.Time
+}
+
+// New returns an initialized Watcher that runs check every interval.
+func New(check CheckFunc, interval time.Duration) *Watcher {
+	return &Watcher{
+		check:     check,
+		interval:  interval,
+		onChange:  func(*report.Report) {},
+		now:       time.Now,
+		lastCheck: time.Now(),
+	}
+}
+
+// SetOnChange sets the function called when the state of the checks changed,
+// including after the first check.
+func (w *Watcher) SetOnChange(f ChangeFunc) *Watcher {
+	w.onChange = f
+	return w
+}
+
+// Run runs the checks immediately, then every interval until stop is closed.
+func (w *Watcher) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		w.runCheck()
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runCheck runs the checks once, calling the change function if the state
+// changed since the last check.
+func (w *Watcher) runCheck() {
+	r := w.check()
+
+	w.mu.Lock()
+	prev := w.last
+	w.last = r
+	w.lastCheck = w.now()
+	w.mu.Unlock()
+
+	if Changed(prev, r) {
+		w.onChange(r)
+	}
+}
+
+// Changed returns true if the status of any script differs between two
+// reports, or if there's no previous report.
+func Changed(prev, cur *report.Report) bool {
+	if prev == nil || len(prev.Scripts) != len(cur.Scripts) {
+		return true
+	}
+
+	statuses := map[string]report.Status{}
+	for _, s := range prev.Scripts {
+		statuses[s.Path] = s.Status
+	}
+	for _, s := range cur.Scripts {
+		if status, ok := statuses[s.Path]; !ok || status != s.Status {
+			return true
+		}
+	}
+	return false
+}
+
+// Handler returns the HTTP handler of the health endpoints. /healthz fails if
+// no check completed for several intervals. /readyz fails until a check
+// completed without failed scripts, and whenever the last check failed.
+func (w *Watcher) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", w.healthz)
+	mux.HandleFunc("/readyz", w.readyz)
+	return mux
+}
+
+// healthz serves the liveness of the watcher.
+func (w *Watcher) healthz(rw http.ResponseWriter, req *http.Request) {
+	w.mu.Lock()
+	lastCheck := w.lastCheck
+	w.mu.Unlock()
+
+	if since := w.now().Sub(lastCheck); since > staleIntervals*w.interval {
+		http.Error(rw, fmt.Sprintf("no completed check for %s", since.Round(time.Second)), http.StatusServiceUnavailable)
+		return
+	}
+	fmt.Fprintln(rw, "ok")
+}
+
+// readyz serves the readiness of the host, the result of the last check.
+func (w *Watcher) readyz(rw http.ResponseWriter, req *http.Request) {
+	w.mu.Lock()
+	last := w.last
+	w.mu.Unlock()
+
+	if last == nil {
+		http.Error(rw, "no completed check", http.StatusServiceUnavailable)
+		return
+	}
+
+	if !last.Success() {
+		var failed []string
+		for _, s := range last.Scripts {
+			if s.Status == report.StatusFailed || s.Status == report.StatusInterrupted {
+				failed = append(failed, fmt.Sprintf("%s: %s", s.Name, s.Error))
+			}
+		}
+		http.Error(rw, fmt.Sprintf("failed scripts: %s", strings.Join(failed, ", ")), http.StatusServiceUnavailable)
+		return
+	}
+	fmt.Fprintln(rw, "ok")
+}
@@ -0,0 +1,126 @@
+package watch
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/storageos/init/report"
+)
+
+func TestChanged(t *testing.T) {
+	passed := &report.Report{Scripts: []*report.Script{
+		{Path: "/scripts/01-lio/enable-lio.sh", Status: report.StatusPassed},
+	}}
+	warning := &report.Report{Scripts: []*report.Script{
+		{Path: "/scripts/01-lio/enable-lio.sh", Status: report.StatusWarning},
+	}}
+	more := &report.Report{Scripts: []*report.Script{
+		{Path: "/scripts/01-lio/enable-lio.sh", Status: report.StatusPassed},
+		{Path: "/scripts/02-limits/limits.sh", Status: report.StatusPassed},
+	}}
+
+	testcases := []struct {
+		name string
+		prev *report.Report
+		cur  *report.Report
+		want bool
+	}{
+		{name: "first check", cur: passed, want: true},
+		{name: "same status", prev: passed, cur: passed, want: false},
+		{name: "status changed", prev: passed, cur: warning, want: true},
+		{name: "scripts changed", prev: passed, cur: more, want: true},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Changed(tc.prev, tc.cur); got != tc.want {
+				t.Errorf("unexpected change:\n\t(WNT) %t\n\t(GOT) %t", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestWatcher(t *testing.T) {
+	// Statuses returned by the successive checks.
+	statuses := []report.Status{report.StatusPassed, report.StatusPassed, report.StatusFailed}
+	checks := 0
+	check := func() *report.Report {
+		status := statuses[checks]
+		checks++
+		return &report.Report{Scripts: []*report.Script{
+			{Name: "01-lio", Path: "/scripts/01-lio/enable-lio.sh", Status: status, Error: "exited 1"},
+		}}
+	}
+
+	changes := 0
+	w := New(check, time.Minute).SetOnChange(func(*report.Report) { changes++ })
+
+	now := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	w.now = func() time.Time { return now }
+	w.lastCheck = now
+
+	// get returns the status code of an endpoint.
+	get := func(path string) int {
+		rec := httptest.NewRecorder()
+		w.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec.Code
+	}
+
+	if code := get("/readyz"); code != http.StatusServiceUnavailable {
+		t.Errorf("unexpected readyz code before any check: %d", code)
+	}
+	if code := get("/healthz"); code != http.StatusOK {
+		t.Errorf("unexpected healthz code before any check: %d", code)
+	}
+
+	wantChanges := []int{1, 1, 2}
+	wantReady := []int{http.StatusOK, http.StatusOK, http.StatusServiceUnavailable}
+	for i := range statuses {
+		w.runCheck()
+		if changes != wantChanges[i] {
+			t.Errorf("unexpected number of changes after check %d:\n\t(WNT) %d\n\t(GOT) %d", i, wantChanges[i], changes)
+		}
+		if code := get("/readyz"); code != wantReady[i] {
+			t.Errorf("unexpected readyz code after check %d:\n\t(WNT) %d\n\t(GOT) %d", i, wantReady[i], code)
+		}
+	}
+
+	// Not healthy when the checks stop completing.
+	now = now.Add(staleIntervals*time.Minute + time.Second)
+	if code := get("/healthz"); code != http.StatusServiceUnavailable {
+		t.Errorf("unexpected healthz code with stale checks: %d", code)
+	}
+}
+
+func TestRun(t *testing.T) {
+	checks := make(chan struct{}, 10)
+	w := New(func() *report.Report {
+		checks <- struct{}{}
+		return &report.Report{}
+	}, 10*time.Millisecond)
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		w.Run(stop)
+		close(done)
+	}()
+
+	// Wait for the immediate check and a periodic one.
+	for i := 0; i < 2; i++ {
+		select {
+		case <-checks:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for check %d", i)
+		}
+	}
+
+	close(stop)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("watcher did not stop")
+	}
+}